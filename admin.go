@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// writeJSONError sends a JSON error body with the given status code. Errors are never
+// cached, so a shared proxy cannot keep serving a 401 or 429 after it has cleared.
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	setCommonHeaders(w)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// Handler for /api/admin/cities/<key> - list, add, update and remove locations
+func adminCitiesHandler(w http.ResponseWriter, r *http.Request) {
+	if !rateLimit(w, r) {
+		return
+	}
+
+	key := strings.ToLower(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/cities"), "/"))
+
+	if r.Method == http.MethodGet {
+		cityLock.RLock()
+		cities := append([]CityConfig(nil), cityRegistry...)
+		cityLock.RUnlock()
+
+		setCommonHeaders(w)
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(CityRegistry{Cities: cities})
+		return
+	}
+
+	if key == "" {
+		writeJSONError(w, http.StatusBadRequest, "City key required")
+		return
+	}
+
+	var city CityConfig
+	switch r.Method {
+	case http.MethodPost, http.MethodPut:
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&city); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		city.Key = key
+		if err := validateCity(city); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	case http.MethodDelete:
+	default:
+		w.Header().Set("Allow", "GET, POST, PUT, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	status := http.StatusOK
+	err := modifyCityRegistry(func(cities []CityConfig) ([]CityConfig, error) {
+		idx := -1
+		for i, c := range cities {
+			if c.Key == key {
+				idx = i
+				break
+			}
+		}
+
+		switch r.Method {
+		case http.MethodPost:
+			if idx >= 0 {
+				status = http.StatusConflict
+				return nil, fmt.Errorf("city %s already exists", key)
+			}
+			status = http.StatusCreated
+			return append(cities, city), nil
+		case http.MethodPut:
+			if idx < 0 {
+				status = http.StatusNotFound
+				return nil, fmt.Errorf("city %s not found", key)
+			}
+			cities[idx] = city
+			return cities, nil
+		default:
+			if idx < 0 {
+				status = http.StatusNotFound
+				return nil, fmt.Errorf("city %s not found", key)
+			}
+			return append(cities[:idx], cities[idx+1:]...), nil
+		}
+	})
+	if err != nil {
+		if status == http.StatusOK || status == http.StatusCreated {
+			status = http.StatusInternalServerError
+		}
+		log.Printf("⚠️ Admin %s %s failed: %v", r.Method, key, err)
+		writeJSONError(w, status, err.Error())
+		return
+	}
+
+	log.Printf("✓ Admin %s city %s", r.Method, key)
+	if r.Method == http.MethodDelete {
+		evictCity(key)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// New or moved locations need fresh data straight away
+	warmCity(key)
+
+	setCommonHeaders(w)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(city)
+}
@@ -0,0 +1,63 @@
+package main
+
+// AlertKind identifies what an alert is about
+type AlertKind string
+
+const (
+	AlertGale         AlertKind = "gale"
+	AlertBura         AlertKind = "bura"
+	AlertJugo         AlertKind = "jugo"
+	AlertThunderstorm AlertKind = "thunderstorm"
+	AlertPressureDrop AlertKind = "pressure_drop"
+)
+
+// Alert warns about hazardous current conditions
+type Alert struct {
+	Kind     AlertKind
+	Severity string // warning or severe
+	Message  string // in the response language
+}
+
+// Alert thresholds, km/h unless noted
+const (
+	GaleBeaufort     = 8   // mean wind, Beaufort force
+	StormBeaufort    = 10  // mean wind, Beaufort force
+	GaleGusts        = 75  // gusts alone
+	BuraWarningGusts = 60  // bridges close to high-sided vehicles
+	BuraSevereGusts  = 100 // motorway sections and ferry lines close
+	JugoWarningSpeed = 40  // high seas on the open coast
+)
+
+// weatherAlerts derives the alerts for current conditions. Speeds must still be metric.
+func weatherAlerts(d WeatherData) []Alert {
+	alerts := make([]Alert, 0)
+	add := func(kind AlertKind, severe bool) {
+		severity := "warning"
+		if severe {
+			severity = "severe"
+		}
+		alerts = append(alerts, Alert{Kind: kind, Severity: severity})
+	}
+
+	// A named local wind replaces the generic gale alert
+	switch {
+	case d.LocalWind == WindBura && d.WindGusts >= BuraWarningGusts:
+		add(AlertBura, d.WindGusts >= BuraSevereGusts)
+	case d.LocalWind == WindJugo && d.WindSpeed >= JugoWarningSpeed:
+		add(AlertJugo, d.Beaufort >= StormBeaufort)
+	case d.Beaufort >= GaleBeaufort || d.WindGusts >= GaleGusts:
+		add(AlertGale, d.Beaufort >= StormBeaufort)
+	}
+
+	switch d.ConditionID {
+	case ConditionThunderstorm:
+		add(AlertThunderstorm, d.Intensity == IntensityHeavy)
+	case ConditionThunderstormHail:
+		add(AlertThunderstorm, true)
+	}
+
+	if t := d.PressureTendency; t != nil && t.Direction == "falling" && t.Rapid {
+		add(AlertPressureDrop, false)
+	}
+	return alerts
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scope is a permission an API key can hold
+type Scope string
+
+const (
+	ScopePublic   Scope = "public" // no key needed, e.g. the dashboard
+	ScopeRead     Scope = "read"
+	ScopeAdmin    Scope = "admin"
+	ScopeShutdown Scope = "shutdown"
+)
+
+var knownScopes = []Scope{ScopeRead, ScopeAdmin, ScopeShutdown}
+
+// APIKey is a stored key; only the SHA-256 of the secret is kept
+type APIKey struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Hash       string    `json:"hash"`
+	Scopes     []Scope   `json:"scopes"`
+	DailyQuota int       `json:"daily_quota"` // requests per UTC day, 0 for unlimited
+	Created    time.Time `json:"created"`
+}
+
+// APIKeyFile is the on-disk format of the key store
+type APIKeyFile struct {
+	Keys []APIKey `json:"keys"`
+}
+
+// HasScope reports whether the key grants scope
+func (k APIKey) HasScope(scope Scope) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// apiKeyStore loads keys from a file and reloads it when it changes on disk
+type apiKeyStore struct {
+	path    string
+	mu      sync.RWMutex
+	keys    map[string]APIKey // by ID
+	modTime time.Time
+}
+
+var apiKeys = &apiKeyStore{path: filepath.Join("data", "apikeys.json")}
+
+// loadAPIKeys reads a key file; a missing file means no keys
+func loadAPIKeys(path string) ([]APIKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file APIKeyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", path, err)
+	}
+	return file.Keys, nil
+}
+
+// saveAPIKeys writes the key file atomically, readable by the owner only
+func saveAPIKeys(path string, keys []APIKey) error {
+	data, err := json.MarshalIndent(APIKeyFile{Keys: keys}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// refresh reloads the key file if it changed since the last load
+func (s *apiKeyStore) refresh() error {
+	info, err := os.Stat(s.path)
+	var modTime time.Time
+	if err == nil {
+		modTime = info.ModTime()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	s.mu.RLock()
+	current := s.keys != nil && modTime.Equal(s.modTime)
+	s.mu.RUnlock()
+	if current {
+		return nil
+	}
+
+	keys, err := loadAPIKeys(s.path)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]APIKey, len(keys))
+	for _, k := range keys {
+		byID[k.ID] = k
+	}
+
+	s.mu.Lock()
+	s.keys, s.modTime = byID, modTime
+	s.mu.Unlock()
+	return nil
+}
+
+// list returns every key, ordered by name
+func (s *apiKeyStore) list() []APIKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b APIKey) int { return strings.Compare(a.Name, b.Name) })
+	return keys
+}
+
+// authenticate finds the key matching a secret of the form wk_<id>_<secret>
+func (s *apiKeyStore) authenticate(secret string) (APIKey, bool) {
+	if err := s.refresh(); err != nil {
+		logKeyStoreError(err)
+	}
+
+	rest, ok := strings.CutPrefix(secret, "wk_")
+	if !ok {
+		return APIKey{}, false
+	}
+	id, _, ok := strings.Cut(rest, "_")
+	if !ok {
+		return APIKey{}, false
+	}
+
+	s.mu.RLock()
+	key, ok := s.keys[id]
+	s.mu.RUnlock()
+	if !ok {
+		return APIKey{}, false
+	}
+	sum := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(key.Hash)) != 1 {
+		return APIKey{}, false
+	}
+	return key, true
+}
+
+// logKeyStoreError reports a broken key file at most once per minute
+var lastKeyStoreError time.Time
+var keyStoreErrorLock sync.Mutex
+
+func logKeyStoreError(err error) {
+	keyStoreErrorLock.Lock()
+	defer keyStoreErrorLock.Unlock()
+	if time.Since(lastKeyStoreError) > time.Minute {
+		lastKeyStoreError = time.Now()
+		log.Printf("⚠️ API key store error, keeping previous keys: %v", err)
+	}
+}
+
+// generateAPIKey creates a new random key and returns it with the secret to hand out
+func generateAPIKey(name string, scopes []Scope, quota int) (APIKey, string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return APIKey{}, "", err
+	}
+	id := hex.EncodeToString(buf[:4])
+	secret := "wk_" + id + "_" + hex.EncodeToString(buf[4:])
+	sum := sha256.Sum256([]byte(secret))
+	return APIKey{
+		ID:         id,
+		Name:       name,
+		Hash:       hex.EncodeToString(sum[:]),
+		Scopes:     scopes,
+		DailyQuota: quota,
+		Created:    time.Now().UTC(),
+	}, secret, nil
+}
+
+// parseScopes reads a comma separated scope list
+func parseScopes(list string) ([]Scope, error) {
+	var scopes []Scope
+	for _, item := range strings.Split(list, ",") {
+		scope := Scope(strings.TrimSpace(item))
+		if !slices.Contains(knownScopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q (use read, admin or shutdown)", scope)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// runKeysCommand implements "weather-server keys create|list|revoke"
+func runKeysCommand(path string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: keys create|list|revoke")
+	}
+	keys, err := loadAPIKeys(path)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("keys create", flag.ContinueOnError)
+		name := fs.String("name", "", "who the key is for (required)")
+		scopeList := fs.String("scopes", string(ScopeRead), "comma separated scopes: read, admin, shutdown")
+		quota := fs.Int("quota", 0, "requests per UTC day, 0 for unlimited")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *name == "" || *quota < 0 {
+			return errors.New("keys create needs -name and a quota of 0 or more")
+		}
+		scopes, err := parseScopes(*scopeList)
+		if err != nil {
+			return err
+		}
+		key, secret, err := generateAPIKey(*name, scopes, *quota)
+		if err != nil {
+			return err
+		}
+		if err := saveAPIKeys(path, append(keys, key)); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Created key %s for %s (scopes: %s)\n", key.ID, key.Name, *scopeList)
+		fmt.Println("  Store it now, it cannot be shown again:")
+		fmt.Println("  " + secret)
+
+	case "list":
+		for _, k := range keys {
+			quota := "unlimited"
+			if k.DailyQuota > 0 {
+				quota = fmt.Sprintf("%d/day", k.DailyQuota)
+			}
+			scopes := make([]string, len(k.Scopes))
+			for i, scope := range k.Scopes {
+				scopes[i] = string(scope)
+			}
+			fmt.Printf("%s  %-20s  %-22s  %s\n", k.ID, k.Name, strings.Join(scopes, ","), quota)
+		}
+
+	case "revoke":
+		if len(args) != 2 {
+			return errors.New("usage: keys revoke <id>")
+		}
+		idx := slices.IndexFunc(keys, func(k APIKey) bool { return k.ID == args[1] })
+		if idx < 0 {
+			return fmt.Errorf("key %s not found", args[1])
+		}
+		if err := saveAPIKeys(path, slices.Delete(keys, idx, idx+1)); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Revoked key %s\n", args[1])
+
+	default:
+		return fmt.Errorf("unknown keys command %q", args[0])
+	}
+	return nil
+}
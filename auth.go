@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AnonymousRead lets requests without a key use read endpoints
+var AnonymousRead = true
+
+// KeyUsage counts one key's requests
+type KeyUsage struct {
+	ID         string
+	Name       string
+	Scopes     []Scope
+	DailyQuota int
+	Day        string // UTC day Today counts
+	Today      int
+	Total      int64
+	LastUsed   string // RFC 3339, empty if never used
+	LastPath   string
+}
+
+// usageTracker keeps per-key counters in memory
+type usageTracker struct {
+	mu    sync.Mutex
+	usage map[string]*KeyUsage
+}
+
+var keyUsage = &usageTracker{usage: make(map[string]*KeyUsage)}
+
+// charge counts a request against a key, refusing it once the daily quota is spent
+func (t *usageTracker) charge(key APIKey, path string, now time.Time) bool {
+	day := now.UTC().Format("2006-01-02")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u, ok := t.usage[key.ID]
+	if !ok {
+		u = &KeyUsage{ID: key.ID}
+		t.usage[key.ID] = u
+	}
+	if u.Day != day {
+		u.Day, u.Today = day, 0
+	}
+	if key.DailyQuota > 0 && u.Today >= key.DailyQuota {
+		return false
+	}
+	u.Today++
+	u.Total++
+	u.LastUsed = now.UTC().Format(time.RFC3339)
+	u.LastPath = path
+	return true
+}
+
+// report lists usage for every stored key, including unused ones
+func (t *usageTracker) report(keys []APIKey, now time.Time) []KeyUsage {
+	day := now.UTC().Format("2006-01-02")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := make([]KeyUsage, 0, len(keys))
+	for _, k := range keys {
+		u := KeyUsage{ID: k.ID, Day: day}
+		if stored, ok := t.usage[k.ID]; ok {
+			u = *stored
+			if u.Day != day {
+				u.Day, u.Today = day, 0
+			}
+		}
+		u.Name, u.Scopes, u.DailyQuota = k.Name, k.Scopes, k.DailyQuota
+		result = append(result, u)
+	}
+	return result
+}
+
+// requireScope wraps a handler so it only runs for callers holding scope.
+// Requests without a key may use public endpoints, and read endpoints while AnonymousRead is on.
+func requireScope(scope Scope, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization, X-API-Key")
+		if r.URL.Query().Has("api_key") {
+			writeJSONError(w, http.StatusBadRequest, "Send the API key in the X-API-Key or Authorization header, not the URL")
+			return
+		}
+		secret := requestAPIKey(r)
+		if secret == "" {
+			if scope == ScopePublic || scope == ScopeRead && AnonymousRead {
+				handler(w, r)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="weather"`)
+			writeJSONError(w, http.StatusUnauthorized, "API key required")
+			return
+		}
+
+		key, ok := apiKeys.authenticate(secret)
+		if !ok {
+			log.Printf("⚠️ Rejected invalid API key from %s for %s", clientIP(r), r.URL.Path)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="weather", error="invalid_token"`)
+			writeJSONError(w, http.StatusUnauthorized, "Invalid API key")
+			return
+		}
+		if scope != ScopePublic && !key.HasScope(scope) {
+			writeJSONError(w, http.StatusForbidden, "API key lacks the "+string(scope)+" scope")
+			return
+		}
+		if !keyUsage.charge(key, r.URL.Path, time.Now()) {
+			w.Header().Set("X-Quota-Limit", strconv.Itoa(key.DailyQuota))
+			writeJSONError(w, http.StatusTooManyRequests, "Daily quota exceeded")
+			return
+		}
+		// Shared caches would otherwise serve this to callers who never paid the key's quota
+		w.Header().Set("Cache-Control", "private, max-age=300")
+		handler(w, r)
+	}
+}
+
+// Handler for /api/admin/usage - request counters per API key
+func adminUsageHandler(w http.ResponseWriter, r *http.Request) {
+	if !rateLimit(w, r) {
+		return
+	}
+	if err := apiKeys.refresh(); err != nil {
+		logKeyStoreError(err)
+	}
+
+	setCommonHeaders(w)
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string][]KeyUsage{"Keys": keyUsage.report(apiKeys.list(), time.Now())})
+}
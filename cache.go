@@ -0,0 +1,322 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Forecast cache tuning
+var (
+	ForecastCacheTTL = 30 * time.Minute // older forecasts are served as stale
+	ForecastMaxStale = 6 * time.Hour    // stale-if-error window
+)
+
+// weatherEntry is everything cached for a city after one provider fetch
+type weatherEntry struct {
+	Data      WeatherData
+	Daily     []DailyConditions
+	Hourly    []HourlyConditions
+	Source    string // provider the data came from
+	Fallback  bool   // mock data standing in for an unavailable upstream
+	Trend     *WeatherTrends
+	Readings  *metricReadings // Data's unrounded readings, which Data itself does not persist
+	Timestamp time.Time
+
+	location *time.Location // city timezone for forecast dates, not persisted
+}
+
+// forecastBody builds the /api/forecast body for an entry in the given language and length
+func (e weatherEntry) forecastBody(stale bool, lang Language, days int) WeatherForecast {
+	data := e.Data
+	data.readings = e.Readings
+	return WeatherForecast{
+		Current:  lang.weather(data),
+		Forecast: forecastFromDaily(e.Daily, days, lang, e.location),
+		Source:   e.Source,
+		Fallback: e.Fallback,
+		Stale:    stale,
+		Trend:    e.Trend,
+		Units:    metricUnits,
+		Language: lang,
+		Timezone: e.location.String(),
+		Updated:  localTimestamp(e.Timestamp, e.location),
+	}
+}
+
+// weatherBody builds the /api/weather body for an entry in the given language and forecast length
+func (e weatherEntry) weatherBody(stale bool, lang Language, days int) WeatherForecast {
+	body := e.forecastBody(stale, lang, days)
+	body.AsciiArt = e.Data.Description
+	return body
+}
+
+// isStale reports whether the entry is older than maxAge
+func (e weatherEntry) isStale(maxAge time.Duration) bool {
+	return time.Since(e.Timestamp) > maxAge
+}
+
+// weatherSnapshot is an immutable cache entry with its fresh default-language API bodies pre-encoded
+type weatherSnapshot struct {
+	weatherEntry
+	weatherJSON  []byte
+	forecastJSON []byte
+}
+
+// newWeatherSnapshot encodes an entry once so handlers can write the bytes directly
+func newWeatherSnapshot(entry weatherEntry) *weatherSnapshot {
+	snap := &weatherSnapshot{weatherEntry: entry}
+	snap.weatherJSON = encodeBody(entry.weatherBody(false, DefaultLanguage, DefaultForecastDays))
+	snap.forecastJSON = encodeBody(entry.forecastBody(false, DefaultLanguage, DefaultForecastDays))
+	return snap
+}
+
+// encodeBody marshals a response the same way json.Encoder would, trailing newline included
+func encodeBody(v any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("⚠️ Failed to encode cached response: %v", err)
+		return []byte("{}\n")
+	}
+	return append(data, '\n')
+}
+
+// lookupCache returns a city's cache slot without locking
+func lookupCache(city string) (*CachedWeatherData, bool) {
+	m := weatherCache.Load()
+	if m == nil {
+		return nil, false
+	}
+	cached, ok := (*m)[city]
+	return cached, ok
+}
+
+// cachedCities returns the cities currently in the cache
+func cachedCities() []string {
+	m := weatherCache.Load()
+	if m == nil {
+		return nil
+	}
+	cities := make([]string, 0, len(*m))
+	for city := range *m {
+		cities = append(cities, city)
+	}
+	return cities
+}
+
+// storeWeather publishes a new snapshot for a city, adding its slot if needed
+func storeWeather(city string, entry weatherEntry) {
+	snap := newWeatherSnapshot(entry)
+	if cached, ok := lookupCache(city); ok {
+		cached.snapshot.Store(snap)
+		return
+	}
+
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	publishWeather(city, snap)
+}
+
+// storeCityWeather records a fetched entry in the city's history and publishes it, unless the
+// city has left the registry. Holding cacheLock throughout keeps evictCity from running between
+// the registry check and the writes, which would leave an orphaned cache slot or history behind.
+func storeCityWeather(city string, entry weatherEntry, current *CurrentConditions) bool {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	if _, ok := lookupCity(city); !ok {
+		return false
+	}
+
+	if current != nil {
+		recordHistory(city, entry.Data, *current, entry.Timestamp)
+	}
+	entry.Trend = analyzeTrends(city, entry.Timestamp)
+	entry.Data.PressureTendency, entry.Data.LocalForecast = pressureOutlook(city, entry.Timestamp)
+	if entry.Fallback && entry.Data.LocalForecast != nil {
+		// The mock forecast knows nothing about the real weather; the barometer history does
+		applyLocalForecast(entry.Daily, entry.Data.LocalForecast)
+	}
+	publishWeather(city, newWeatherSnapshot(entry))
+	return true
+}
+
+// publishWeather stores a snapshot in the city's slot, adding the slot if needed.
+// The caller holds cacheLock.
+func publishWeather(city string, snap *weatherSnapshot) {
+	if cached, ok := lookupCache(city); ok {
+		cached.snapshot.Store(snap)
+		return
+	}
+	old := weatherCache.Load()
+	next := make(map[string]*CachedWeatherData)
+	if old != nil {
+		for k, v := range *old {
+			next[k] = v
+		}
+	}
+	cached := &CachedWeatherData{}
+	cached.snapshot.Store(snap)
+	next[city] = cached
+	weatherCache.Store(&next)
+}
+
+// deleteWeather removes a city's slot from the cache
+func deleteWeather(city string) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	dropWeather(city)
+}
+
+// dropWeather removes a city's slot from the cache. The caller holds cacheLock.
+func dropWeather(city string) {
+	old := weatherCache.Load()
+	if old == nil {
+		return
+	}
+	next := make(map[string]*CachedWeatherData, len(*old))
+	for k, v := range *old {
+		if k != city {
+			next[k] = v
+		}
+	}
+	weatherCache.Store(&next)
+}
+
+// saveCacheState writes every cached entry to path so a restart can serve it while the upstream is down
+func saveCacheState(path string) error {
+	entries := make(map[string]weatherEntry)
+	for _, city := range cachedCities() {
+		if snap, ok := getCachedWeather(city); ok {
+			entries[city] = snap.weatherEntry
+		}
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadCacheState restores entries saved at the last shutdown for cities still in the registry.
+// Entries past ForecastMaxStale are dropped.
+func loadCacheState(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var entries map[string]weatherEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	restored := 0
+	for city, entry := range entries {
+		coords, ok := lookupCity(city)
+		if !ok || entry.isStale(ForecastMaxStale) {
+			continue
+		}
+		entry.location = cityLocation(coords)
+		storeWeather(city, entry)
+		restored++
+	}
+	log.Printf("✓ Restored %d cached cities from %s", restored, path)
+	return nil
+}
+
+// newWeatherEntry converts a provider report into a cache entry
+func newWeatherEntry(coords CityCoordinates, report *WeatherReport, fallback bool) weatherEntry {
+	data := weatherDataFromReport(coords, report)
+	return weatherEntry{
+		Data:      *data,
+		Daily:     report.Daily,
+		Hourly:    report.Hourly,
+		Source:    report.Source,
+		Fallback:  fallback,
+		Readings:  data.readings,
+		Timestamp: time.Now(),
+		location:  cityLocation(coords),
+	}
+}
+
+// refreshCall is one in-flight refresh that other callers can wait on
+type refreshCall struct {
+	wg  sync.WaitGroup
+	err error
+}
+
+// refreshGroup makes concurrent refreshes of the same key share one upstream call
+type refreshGroup struct {
+	mu    sync.Mutex
+	calls map[string]*refreshCall
+}
+
+// Do runs fn once per key at a time; callers arriving meanwhile get the same result
+func (g *refreshGroup) Do(key string, fn func() error) error {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*refreshCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.err
+	}
+	call := &refreshCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	call.err = fn()
+	call.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	return call.err
+}
+
+var cityRefreshes refreshGroup
+
+// refreshCity fetches fresh data for a city from the active provider and stores it in the cache
+func refreshCity(location string) error {
+	return cityRefreshes.Do(location, func() error {
+		coords, report, err := fetchRealWeather(location)
+		if err != nil {
+			return err
+		}
+		entry := newWeatherEntry(coords, report, false)
+
+		// Cities whose warm-up failed get their first entry here
+		if !storeCityWeather(location, entry, &report.Current) {
+			// Removed while we were fetching
+			return nil
+		}
+
+		log.Printf("Successfully refreshed weather data for %s: %d°C, %s", location, entry.Data.Temperature, entry.Data.Condition)
+		return nil
+	})
+}
+
+// getCachedWeather returns a city's current snapshot. The background scheduler keeps
+// snapshots fresh, so this never blocks on the upstream or on a lock.
+func getCachedWeather(location string) (*weatherSnapshot, bool) {
+	cached, ok := lookupCache(location)
+	if !ok {
+		return nil, false
+	}
+	snap := cached.snapshot.Load()
+	return snap, snap != nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// storeBenchmarkCity caches a week of weather for a city outside the registry
+func storeBenchmarkCity(b *testing.B, city string) {
+	b.Helper()
+	pressure, gusts := 1015.2, 31.0
+	report := &WeatherReport{
+		Source: "benchmark",
+		Current: CurrentConditions{
+			Time:          time.Now().Format("2006-01-02T15:04"),
+			Temperature:   14.6,
+			Humidity:      70,
+			WindSpeed:     18,
+			WindDirection: 120,
+			WindGusts:     &gusts,
+			PressureMSL:   &pressure,
+			WeatherCode:   61,
+			IsDay:         true,
+		},
+	}
+	for i := 0; i < MaxForecastDays; i++ {
+		report.Daily = append(report.Daily, DailyConditions{
+			Date:           time.Now().AddDate(0, 0, i).Format("2006-01-02"),
+			WeatherCode:    3,
+			TemperatureMax: 17,
+			TemperatureMin: 9,
+		})
+	}
+	coords := CityCoordinates{Name: "Benchmark", Latitude: 45.8, Longitude: 16, Timezone: "Europe/Zagreb"}
+	storeWeather(city, newWeatherEntry(coords, report, false))
+	b.Cleanup(func() { deleteWeather(city) })
+}
+
+// BenchmarkWeatherAPIHandler reads one cached city from many goroutines, each its own client.
+// The cache read takes no lock and the hot path does not log; the rate limiter locks only the
+// shard of the client's bucket, so with -cpu 1,4 -mutexprofile clients on different shards never wait.
+func BenchmarkWeatherAPIHandler(b *testing.B) {
+	storeBenchmarkCity(b, "benchmark")
+	limiter := requestLimiter
+	requestLimiter = newClientLimiter(1<<30, 1<<30)
+	b.Cleanup(func() { requestLimiter = limiter })
+
+	for _, bm := range []struct{ name, query string }{
+		{"PreEncoded", ""},
+		{"Reencoded", "?lang=en&units=imperial"},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			var client atomic.Int64
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				r := httptest.NewRequest(http.MethodGet, "/api/weather/benchmark"+bm.query, nil)
+				r.RemoteAddr = fmt.Sprintf("192.0.2.%d:1234", client.Add(1)%250)
+				for pb.Next() {
+					w := httptest.NewRecorder()
+					weatherAPIHandler(w, r)
+					if w.Code != http.StatusOK {
+						b.Fatalf("status %d: %s", w.Code, w.Body)
+					}
+				}
+			})
+		})
+	}
+}
+
+// TestStoreCityWeatherUnregistered checks a refresh finishing after its city was removed leaves nothing behind
+func TestStoreCityWeatherUnregistered(t *testing.T) {
+	report := &WeatherReport{Source: "test", Current: CurrentConditions{Time: time.Now().Format("2006-01-02T15:04"), Temperature: 12}}
+	entry := newWeatherEntry(CityCoordinates{Name: "Removed", Timezone: "UTC"}, report, false)
+	if storeCityWeather("removed-city", entry, &report.Current) {
+		t.Fatal("storeCityWeather accepted a city outside the registry")
+	}
+	if _, ok := lookupCache("removed-city"); ok {
+		t.Error("removed city got a cache slot")
+	}
+	obs, err := historyStore.Observations("removed-city", time.Time{}, time.Now().Add(time.Hour))
+	if err != nil || len(obs) != 0 {
+		t.Errorf("removed city history = %d observations, %v; want none", len(obs), err)
+	}
+}
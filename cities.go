@@ -0,0 +1,335 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"sync"
+	"syscall"
+	"time"
+	_ "time/tzdata" // timezone validation must work on hosts without a zoneinfo database
+)
+
+// Built-in registry, used when no registry file exists on disk
+//
+//go:embed cities.json
+var defaultCityRegistry []byte
+
+// CityConfig is one location in the city registry file
+type CityConfig struct {
+	Key       string        `json:"key"`
+	Name      string        `json:"name"`
+	Emoji     string        `json:"emoji"`
+	Latitude  float64       `json:"lat"`
+	Longitude float64       `json:"lon"`
+	Timezone  string        `json:"timezone"`
+	Coastal   bool          `json:"coastal,omitempty"` // Adriatic wind regimes (bura, jugo, maestral) apply
+	Mock      *MockBaseline `json:"mock,omitempty"`
+}
+
+// MockBaseline is the fallback weather used by the mock provider
+type MockBaseline struct {
+	Temperature int    `json:"temperature"`
+	Condition   string `json:"condition"` // condition key or name in any language
+	WindSpeed   int    `json:"wind_speed"`
+	Humidity    int    `json:"humidity"`
+	FeelsLike   int    `json:"feels_like"`
+}
+
+// CityRegistry is the on-disk registry format
+type CityRegistry struct {
+	Cities []CityConfig `json:"cities"`
+}
+
+var cityKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Registry state; cityCoordinates and locations are derived from cityRegistry
+var cityRegistry []CityConfig
+var cityLock sync.RWMutex
+
+// Registry file and the lock serialising reloads and admin changes
+var cityRegistryPath = "cities.json"
+var registryUpdateLock sync.Mutex
+
+// loadCityRegistry reads and validates a registry file, falling back to the built-in one
+func loadCityRegistry(path string) ([]CityConfig, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("City registry %s not found, using built-in registry", path)
+		data = defaultCityRegistry
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read city registry: %w", err)
+	}
+
+	var registry CityRegistry
+	if err := json.Unmarshal(data, &registry); err != nil {
+		return nil, fmt.Errorf("failed to parse city registry %s: %w", path, err)
+	}
+	if err := validateCities(registry.Cities); err != nil {
+		return nil, fmt.Errorf("invalid city registry %s: %w", path, err)
+	}
+	return registry.Cities, nil
+}
+
+// saveCityRegistry writes the registry atomically so a crash never leaves half a file
+func saveCityRegistry(path string, cities []CityConfig) error {
+	data, err := json.MarshalIndent(CityRegistry{Cities: cities}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write city registry: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace city registry: %w", err)
+	}
+	return nil
+}
+
+// modifyCityRegistry applies a change to a copy of the registry, then validates, persists and applies it
+func modifyCityRegistry(change func([]CityConfig) ([]CityConfig, error)) error {
+	registryUpdateLock.Lock()
+	defer registryUpdateLock.Unlock()
+
+	cityLock.RLock()
+	current := append([]CityConfig(nil), cityRegistry...)
+	cityLock.RUnlock()
+
+	next, err := change(current)
+	if err != nil {
+		return err
+	}
+	if err := validateCities(next); err != nil {
+		return err
+	}
+	if err := saveCityRegistry(cityRegistryPath, next); err != nil {
+		return err
+	}
+	applyCityRegistry(next)
+	return nil
+}
+
+// validateCity checks a single registry entry
+func validateCity(c CityConfig) error {
+	var errs []error
+	if !cityKeyPattern.MatchString(c.Key) {
+		errs = append(errs, fmt.Errorf("city %q: key must be lowercase letters, digits or dashes", c.Key))
+	}
+	if c.Name == "" {
+		errs = append(errs, fmt.Errorf("city %q: name is required", c.Key))
+	}
+	if c.Latitude < -90 || c.Latitude > 90 {
+		errs = append(errs, fmt.Errorf("city %q: latitude %.4f out of range", c.Key, c.Latitude))
+	}
+	if c.Longitude < -180 || c.Longitude > 180 {
+		errs = append(errs, fmt.Errorf("city %q: longitude %.4f out of range", c.Key, c.Longitude))
+	}
+	if c.Timezone == "" {
+		errs = append(errs, fmt.Errorf("city %q: timezone is required", c.Key))
+	} else if _, err := time.LoadLocation(c.Timezone); err != nil {
+		errs = append(errs, fmt.Errorf("city %q: %w", c.Key, err))
+	}
+	if c.Mock != nil {
+		if _, ok := parseCondition(c.Mock.Condition); !ok {
+			errs = append(errs, fmt.Errorf("city %q: unknown mock condition %q", c.Key, c.Mock.Condition))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateCities checks every entry and rejects duplicate keys
+func validateCities(cities []CityConfig) error {
+	if len(cities) == 0 {
+		return fmt.Errorf("no cities defined")
+	}
+	var errs []error
+	seen := make(map[string]bool)
+	for _, c := range cities {
+		if seen[c.Key] {
+			errs = append(errs, fmt.Errorf("city %q: duplicate key", c.Key))
+		}
+		seen[c.Key] = true
+		if err := validateCity(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyCityRegistry swaps in a new registry and returns the keys that were added and removed
+func applyCityRegistry(cities []CityConfig) (added, removed []string) {
+	coords := make(map[string]CityCoordinates, len(cities))
+	mocks := make(map[string]WeatherData)
+	for _, c := range cities {
+		coords[c.Key] = CityCoordinates{
+			Name:      c.Name + " " + c.Emoji,
+			Latitude:  c.Latitude,
+			Longitude: c.Longitude,
+			Emoji:     c.Emoji,
+			Timezone:  c.Timezone,
+			Coastal:   c.Coastal,
+		}
+		if c.Mock != nil {
+			condition, _ := parseCondition(c.Mock.Condition)
+			mocks[c.Key] = WeatherData{
+				Location:    c.Name + " " + c.Emoji,
+				Temperature: c.Mock.Temperature,
+				ConditionID: condition,
+				Condition:   DefaultLanguage.ConditionName(condition),
+				Emoji:       condition.Emoji(),
+				WindSpeed:   c.Mock.WindSpeed,
+				Humidity:    c.Mock.Humidity,
+				FeelsLike:   c.Mock.FeelsLike,
+			}
+		}
+	}
+
+	cityLock.Lock()
+	old := cityCoordinates
+	cityRegistry = cities
+	cityCoordinates = coords
+	locations = mocks
+	cityLock.Unlock()
+
+	for _, c := range cities {
+		if _, ok := old[c.Key]; !ok {
+			added = append(added, c.Key)
+		}
+	}
+	for key := range old {
+		if _, ok := coords[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	return added, removed
+}
+
+// lookupCity returns the registry entry for a city key
+func lookupCity(key string) (CityCoordinates, bool) {
+	cityLock.RLock()
+	defer cityLock.RUnlock()
+	coords, ok := cityCoordinates[key]
+	return coords, ok
+}
+
+// cityLocation returns the city's timezone, falling back to DefaultTimezone
+func cityLocation(coords CityCoordinates) *time.Location {
+	if loc, err := time.LoadLocation(coords.Timezone); err == nil && coords.Timezone != "" {
+		return loc
+	}
+	if loc, err := time.LoadLocation(DefaultTimezone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// cityKeys returns city keys in registry order
+func cityKeys() []string {
+	cityLock.RLock()
+	defer cityLock.RUnlock()
+	keys := make([]string, 0, len(cityRegistry))
+	for _, c := range cityRegistry {
+		keys = append(keys, c.Key)
+	}
+	return keys
+}
+
+// mockBaseline returns the mock weather for a city, if the registry defines one
+func mockBaseline(key string) (WeatherData, bool) {
+	cityLock.RLock()
+	defer cityLock.RUnlock()
+	data, ok := locations[key]
+	return data, ok
+}
+
+// warmCity fetches weather for a city, falling back to mock data, and caches it
+func warmCity(city string) bool {
+	log.Printf("Initializing weather data for %s", city)
+
+	// Try to fetch real weather data
+	fallback := false
+	coords, report, err := fetchRealWeather(city)
+	if err != nil {
+		// Real data restored from the last shutdown beats mock data
+		if snap, ok := getCachedWeather(city); ok && !snap.Fallback {
+			log.Printf("⚠️ Failed to fetch real weather for %s: %v. Keeping cached data from %s.\n", city, err, snap.Timestamp.Format(time.RFC3339))
+			return true
+		}
+		// Fallback to mock data if API fails
+		log.Printf("⚠️ Failed to fetch real weather for %s: %v. Using fallback data.\n", city, err)
+		coords, report, err = fetchWeatherFrom(fallbackProvider, city)
+		if err != nil {
+			log.Printf("⚠️ No mock data available for %s. Skipping.\n", city)
+			return false
+		}
+		fallback = true
+	}
+	entry := newWeatherEntry(coords, report, fallback)
+	current := &report.Current
+	if fallback {
+		current = nil // mock readings stay out of the history
+	} else {
+		fmt.Printf("✓ Loaded real weather for %s: %.0f°C, %s\n", city, float64(entry.Data.Temperature), entry.Data.Condition)
+	}
+	if !storeCityWeather(city, entry, current) {
+		log.Printf("⚠️ %s was removed while loading. Skipping.\n", city)
+		return false
+	}
+	log.Printf("✓ Weather data cached for %s\n", city)
+	return true
+}
+
+// evictCity drops cached weather and history for a city
+func evictCity(city string) {
+	// Under cacheLock a refresh either finished writing before us or sees the city gone
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	dropWeather(city)
+	if err := historyStore.Forget(city); err != nil {
+		log.Printf("⚠️ Evicted %s from cache, but failed to delete its history: %v\n", city, err)
+		return
+	}
+	log.Printf("✓ Evicted %s from cache and history\n", city)
+}
+
+// syncCityState evicts removed cities, refreshes the rest and warms added ones
+func syncCityState(added, removed []string) {
+	for _, city := range removed {
+		evictCity(city)
+	}
+
+	// Coordinates may have changed, so refresh the remaining cities too
+	for _, city := range cachedCities() {
+		go refreshCity(city)
+	}
+
+	for _, city := range added {
+		warmCity(city)
+	}
+}
+
+// watchCityRegistry reloads the registry file on SIGHUP
+func watchCityRegistry() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		log.Printf("🔄 SIGHUP received, reloading city registry from %s", cityRegistryPath)
+		registryUpdateLock.Lock()
+		cities, err := loadCityRegistry(cityRegistryPath)
+		if err != nil {
+			registryUpdateLock.Unlock()
+			log.Printf("⚠️ Keeping current city registry: %v", err)
+			continue
+		}
+		added, removed := applyCityRegistry(cities)
+		registryUpdateLock.Unlock()
+		syncCityState(added, removed)
+		log.Printf("✓ City registry reloaded: %d cities, %d added, %d removed", len(cities), len(added), len(removed))
+	}
+}
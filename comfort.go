@@ -0,0 +1,41 @@
+package main
+
+import "math"
+
+// windChill returns the wind chill in °C (Environment Canada formula, wind in km/h)
+func windChill(tempC, windKmh float64) float64 {
+	v := math.Pow(windKmh, 0.16)
+	return 13.12 + 0.6215*tempC - 11.37*v + 0.3965*tempC*v
+}
+
+// heatIndex returns the heat index in °C (NOAA Rothfusz regression)
+func heatIndex(tempC float64, humidity int) float64 {
+	t := tempC*9/5 + 32
+	rh := float64(humidity)
+	hi := -42.379 + 2.04901523*t + 10.14333127*rh -
+		0.22475541*t*rh - 0.00683783*t*t - 0.05481717*rh*rh +
+		0.00122874*t*t*rh + 0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+	return (hi - 32) * 5 / 9
+}
+
+// dewPoint returns the dew point in °C (Magnus formula)
+func dewPoint(tempC float64, humidity int) float64 {
+	const a, b = 17.62, 243.12
+	if humidity <= 0 {
+		humidity = 1
+	}
+	gamma := math.Log(float64(humidity)/100) + a*tempC/(b+tempC)
+	return b * gamma / (a - gamma)
+}
+
+// feelsLike estimates the apparent temperature when the provider does not supply one
+func feelsLike(tempC float64, humidity int, windKmh float64) float64 {
+	switch {
+	case tempC <= 10 && windKmh > 4.8:
+		return windChill(tempC, windKmh)
+	case tempC >= 26.7 && humidity >= 40:
+		return heatIndex(tempC, humidity)
+	default:
+		return tempC
+	}
+}
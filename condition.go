@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Condition is a language-neutral weather condition; display text comes from the catalogs in i18n.go
+type Condition int
+
+const (
+	ConditionClear Condition = iota
+	ConditionPartlyCloudy
+	ConditionCloudy
+	ConditionFog
+	ConditionRain
+	ConditionSnow
+	ConditionShowers
+	ConditionSnowShowers
+	ConditionThunderstorm
+	ConditionMainlyClear
+	ConditionDrizzle
+	ConditionFreezingDrizzle
+	ConditionFreezingRain
+	ConditionSnowGrains
+	ConditionThunderstormHail
+)
+
+// conditionKeys are the stable identifiers used in JSON and URLs
+var conditionKeys = map[Condition]string{
+	ConditionClear:            "clear",
+	ConditionMainlyClear:      "mainly_clear",
+	ConditionPartlyCloudy:     "partly_cloudy",
+	ConditionCloudy:           "cloudy",
+	ConditionFog:              "fog",
+	ConditionDrizzle:          "drizzle",
+	ConditionFreezingDrizzle:  "freezing_drizzle",
+	ConditionRain:             "rain",
+	ConditionFreezingRain:     "freezing_rain",
+	ConditionSnow:             "snow",
+	ConditionSnowGrains:       "snow_grains",
+	ConditionShowers:          "showers",
+	ConditionSnowShowers:      "snow_showers",
+	ConditionThunderstorm:     "thunderstorm",
+	ConditionThunderstormHail: "thunderstorm_hail",
+}
+
+var conditionEmojis = map[Condition]string{
+	ConditionClear:            "☀️",
+	ConditionMainlyClear:      "🌤️",
+	ConditionPartlyCloudy:     "⛅",
+	ConditionCloudy:           "☁️",
+	ConditionFog:              "🌫️",
+	ConditionDrizzle:          "🌦️",
+	ConditionFreezingDrizzle:  "🧊",
+	ConditionRain:             "🌧️",
+	ConditionFreezingRain:     "🧊",
+	ConditionSnow:             "❄️",
+	ConditionSnowGrains:       "🌨️",
+	ConditionShowers:          "🌦️",
+	ConditionSnowShowers:      "🌨️",
+	ConditionThunderstorm:     "⛈️",
+	ConditionThunderstormHail: "⛈️",
+}
+
+// nightEmojis replace the sunny icons between sunset and sunrise
+var nightEmojis = map[Condition]string{
+	ConditionClear:        "🌙",
+	ConditionMainlyClear:  "🌙",
+	ConditionPartlyCloudy: "☁️",
+	ConditionDrizzle:      "🌧️",
+	ConditionShowers:      "🌧️",
+}
+
+func (c Condition) String() string {
+	if key, ok := conditionKeys[c]; ok {
+		return key
+	}
+	return fmt.Sprintf("condition(%d)", int(c))
+}
+
+// Emoji returns the daytime icon shown next to the condition
+func (c Condition) Emoji() string {
+	return conditionEmojis[c]
+}
+
+// EmojiAt returns the icon for day or night
+func (c Condition) EmojiAt(isDay bool) string {
+	if night, ok := nightEmojis[c]; ok && !isDay {
+		return night
+	}
+	return c.Emoji()
+}
+
+func (c Condition) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Condition) UnmarshalText(text []byte) error {
+	parsed, ok := parseCondition(string(text))
+	if !ok {
+		return fmt.Errorf("unknown condition %q", text)
+	}
+	*c = parsed
+	return nil
+}
+
+// parseCondition accepts a condition key ("clear") or its name in any catalog ("Sunčano", "Sunny")
+func parseCondition(s string) (Condition, bool) {
+	s = strings.TrimSpace(s)
+	for c, key := range conditionKeys {
+		if strings.EqualFold(s, key) {
+			return c, true
+		}
+	}
+	for _, cat := range catalogs {
+		for c, name := range cat.conditions {
+			if strings.EqualFold(s, name) {
+				return c, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Intensity grades precipitation and storms; empty for conditions without one
+type Intensity string
+
+const (
+	IntensityLight    Intensity = "light"
+	IntensityModerate Intensity = "moderate"
+	IntensityHeavy    Intensity = "heavy"
+)
+
+// wmoCode is one entry of the WMO 4677 table
+type wmoCode struct {
+	condition Condition
+	intensity Intensity
+}
+
+// wmoCodes lists every WMO 4677 code Open-Meteo reports
+// https://open-meteo.com/en/docs#weather_variable_documentation
+var wmoCodes = map[int]wmoCode{
+	0:  {ConditionClear, ""},
+	1:  {ConditionMainlyClear, ""},
+	2:  {ConditionPartlyCloudy, ""},
+	3:  {ConditionCloudy, ""},
+	45: {ConditionFog, ""},
+	48: {ConditionFog, ""}, // depositing rime fog
+	51: {ConditionDrizzle, IntensityLight},
+	53: {ConditionDrizzle, IntensityModerate},
+	55: {ConditionDrizzle, IntensityHeavy},
+	56: {ConditionFreezingDrizzle, IntensityLight},
+	57: {ConditionFreezingDrizzle, IntensityHeavy},
+	61: {ConditionRain, IntensityLight},
+	63: {ConditionRain, IntensityModerate},
+	65: {ConditionRain, IntensityHeavy},
+	66: {ConditionFreezingRain, IntensityLight},
+	67: {ConditionFreezingRain, IntensityHeavy},
+	71: {ConditionSnow, IntensityLight},
+	73: {ConditionSnow, IntensityModerate},
+	75: {ConditionSnow, IntensityHeavy},
+	77: {ConditionSnowGrains, ""},
+	80: {ConditionShowers, IntensityLight},
+	81: {ConditionShowers, IntensityModerate},
+	82: {ConditionShowers, IntensityHeavy},
+	85: {ConditionSnowShowers, IntensityLight},
+	86: {ConditionSnowShowers, IntensityHeavy},
+	95: {ConditionThunderstorm, IntensityModerate},
+	96: {ConditionThunderstormHail, IntensityLight},
+	99: {ConditionThunderstormHail, IntensityHeavy},
+}
+
+// lookupWMOCode returns the condition and intensity of a WMO code. Codes outside the
+// table are placed by their WMO range so a storm is never reported as clouds.
+func lookupWMOCode(code int) (Condition, Intensity) {
+	if wc, ok := wmoCodes[code]; ok {
+		return wc.condition, wc.intensity
+	}
+	switch {
+	case code >= 90:
+		return ConditionThunderstorm, ""
+	case code >= 80:
+		return ConditionShowers, ""
+	case code >= 70:
+		return ConditionSnow, ""
+	case code >= 60:
+		return ConditionRain, ""
+	case code >= 50:
+		return ConditionDrizzle, ""
+	case code >= 40:
+		return ConditionFog, ""
+	default:
+		return ConditionCloudy, ""
+	}
+}
+
+// conditionToWMOCode finds the lowest WMO code for the given condition, or -1
+func conditionToWMOCode(condition Condition) int {
+	best := -1
+	for code, wc := range wmoCodes {
+		if wc.condition == condition && (best < 0 || code < best) {
+			best = code
+		}
+	}
+	return best
+}
@@ -0,0 +1,114 @@
+package main
+
+import "testing"
+
+func TestLookupWMOCode(t *testing.T) {
+	tests := []struct {
+		code      int
+		condition Condition
+		intensity Intensity
+	}{
+		{0, ConditionClear, ""},
+		{1, ConditionMainlyClear, ""},
+		{2, ConditionPartlyCloudy, ""},
+		{3, ConditionCloudy, ""},
+		{45, ConditionFog, ""},
+		{48, ConditionFog, ""},
+		{51, ConditionDrizzle, IntensityLight},
+		{53, ConditionDrizzle, IntensityModerate},
+		{55, ConditionDrizzle, IntensityHeavy},
+		{56, ConditionFreezingDrizzle, IntensityLight},
+		{57, ConditionFreezingDrizzle, IntensityHeavy},
+		{61, ConditionRain, IntensityLight},
+		{63, ConditionRain, IntensityModerate},
+		{65, ConditionRain, IntensityHeavy},
+		{66, ConditionFreezingRain, IntensityLight},
+		{67, ConditionFreezingRain, IntensityHeavy},
+		{71, ConditionSnow, IntensityLight},
+		{73, ConditionSnow, IntensityModerate},
+		{75, ConditionSnow, IntensityHeavy},
+		{77, ConditionSnowGrains, ""},
+		{80, ConditionShowers, IntensityLight},
+		{81, ConditionShowers, IntensityModerate},
+		{82, ConditionShowers, IntensityHeavy},
+		{85, ConditionSnowShowers, IntensityLight},
+		{86, ConditionSnowShowers, IntensityHeavy},
+		{95, ConditionThunderstorm, IntensityModerate},
+		{96, ConditionThunderstormHail, IntensityLight},
+		{99, ConditionThunderstormHail, IntensityHeavy},
+
+		// Codes outside the table fall back by WMO range
+		{4, ConditionCloudy, ""},
+		{44, ConditionFog, ""},
+		{50, ConditionDrizzle, ""},
+		{60, ConditionRain, ""},
+		{70, ConditionSnow, ""},
+		{83, ConditionShowers, ""},
+		{90, ConditionThunderstorm, ""},
+		{97, ConditionThunderstorm, ""},
+	}
+
+	inTable := 0
+	for _, tt := range tests {
+		if _, ok := wmoCodes[tt.code]; ok {
+			inTable++
+		}
+		condition, intensity := lookupWMOCode(tt.code)
+		if condition != tt.condition || intensity != tt.intensity {
+			t.Errorf("lookupWMOCode(%d) = %v, %q; want %v, %q", tt.code, condition, intensity, tt.condition, tt.intensity)
+		}
+	}
+	if inTable != len(wmoCodes) {
+		t.Errorf("tests cover %d of %d codes in wmoCodes", inTable, len(wmoCodes))
+	}
+}
+
+func TestEmojiAt(t *testing.T) {
+	tests := []struct {
+		condition Condition
+		isDay     bool
+		want      string
+	}{
+		{ConditionClear, true, "☀️"},
+		{ConditionClear, false, "🌙"},
+		{ConditionMainlyClear, false, "🌙"},
+		{ConditionPartlyCloudy, true, "⛅"},
+		{ConditionPartlyCloudy, false, "☁️"},
+		{ConditionShowers, false, "🌧️"},
+		{ConditionThunderstorm, true, "⛈️"},
+		{ConditionThunderstorm, false, "⛈️"},
+	}
+	for _, tt := range tests {
+		if got := tt.condition.EmojiAt(tt.isDay); got != tt.want {
+			t.Errorf("%v.EmojiAt(%v) = %q, want %q", tt.condition, tt.isDay, got, tt.want)
+		}
+	}
+}
+
+func TestGetAsciiArt(t *testing.T) {
+	tests := []struct {
+		condition Condition
+		isDay     bool
+		want      string
+	}{
+		{ConditionClear, true, asciiArts[ConditionClear]},
+		{ConditionClear, false, nightArts[ConditionClear]},
+		{ConditionMainlyClear, false, nightArts[ConditionClear]},
+		{ConditionPartlyCloudy, true, asciiArts[ConditionPartlyCloudy]},
+		{ConditionPartlyCloudy, false, nightArts[ConditionPartlyCloudy]},
+		{ConditionRain, false, asciiArts[ConditionRain]},
+		{ConditionThunderstormHail, true, asciiArts[ConditionThunderstorm]},
+	}
+	for _, tt := range tests {
+		if got := getAsciiArt(tt.condition, tt.isDay); got != tt.want {
+			t.Errorf("getAsciiArt(%v, %v) returned the wrong art:\n%s", tt.condition, tt.isDay, got)
+		}
+	}
+	for c := range conditionKeys {
+		for _, isDay := range []bool{true, false} {
+			if got := getAsciiArt(c, isDay); got == "" || got == "   (...weather brewing...)" {
+				t.Errorf("getAsciiArt(%v, %v) has no art", c, isDay)
+			}
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Config is the effective server configuration. Every setting can come from a flag
+// (--rate-burst), an environment variable (WEATHER_RATE_BURST) or the JSON config
+// file (rate_burst), in that order of precedence.
+type Config struct {
+	ConfigFile  string
+	PrintConfig bool
+	NoBrowser   bool
+
+	Listen          string
+	RefreshInterval time.Duration
+	APITimeout      time.Duration
+	Timezone        string
+
+	Provider  string
+	APIURL    string
+	ReplayDir string
+	RecordDir string
+
+	DataDir    string
+	CitiesFile string
+	KeysFile   string
+
+	ForecastTTL      time.Duration
+	ForecastMaxStale time.Duration
+
+	RateBurst      int
+	RatePerMinute  int
+	TrustedProxies string
+	AnonymousRead  bool
+
+	HistoryStore  string
+	HistoryRaw    time.Duration
+	HistoryHourly time.Duration
+	HistoryDaily  time.Duration
+	TrendWindow   time.Duration
+}
+
+// defaultConfig returns the settings used when nothing overrides them
+func defaultConfig() Config {
+	return Config{
+		Listen:           ":8081",
+		RefreshInterval:  CacheRefreshInterval,
+		APITimeout:       APITimeout,
+		Timezone:         DefaultTimezone,
+		Provider:         "openmeteo",
+		DataDir:          "data",
+		CitiesFile:       "cities.json",
+		ForecastTTL:      ForecastCacheTTL,
+		ForecastMaxStale: ForecastMaxStale,
+		RateBurst:        RateLimitBurst,
+		RatePerMinute:    RateLimitPerMinute,
+		AnonymousRead:    AnonymousRead,
+		HistoryStore:     "disk",
+		HistoryRaw:       HistoryRawRetention,
+		HistoryHourly:    HistoryHourlyRetention,
+		HistoryDaily:     HistoryDailyRetention,
+		TrendWindow:      TrendWindow,
+	}
+}
+
+// configFlags binds every setting of c to a flag, using the current values as defaults
+func configFlags(c *Config, output io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("weather-server", flag.ContinueOnError)
+	fs.SetOutput(output)
+
+	fs.StringVar(&c.Listen, "listen", c.Listen, "address to listen on")
+	fs.DurationVar(&c.RefreshInterval, "refresh-interval", c.RefreshInterval, "how often each city is refreshed")
+	fs.DurationVar(&c.APITimeout, "api-timeout", c.APITimeout, "timeout for upstream requests")
+	fs.StringVar(&c.Timezone, "timezone", c.Timezone, "fallback timezone for locations without a valid one")
+
+	fs.StringVar(&c.Provider, "provider", c.Provider, "weather source: openmeteo, mock or replay")
+	fs.StringVar(&c.APIURL, "api-url", c.APIURL, "upstream base URL (default Open-Meteo)")
+	fs.StringVar(&c.ReplayDir, "replay-dir", c.ReplayDir, "recorded responses for the replay provider")
+	fs.StringVar(&c.RecordDir, "record-dir", c.RecordDir, "directory to record raw upstream responses to")
+
+	fs.StringVar(&c.DataDir, "data-dir", c.DataDir, "directory for history, API keys and saved cache")
+	fs.StringVar(&c.CitiesFile, "cities-file", c.CitiesFile, "city registry file")
+	fs.StringVar(&c.KeysFile, "keys-file", c.KeysFile, "API key file (default <data-dir>/apikeys.json)")
+
+	fs.DurationVar(&c.ForecastTTL, "forecast-ttl", c.ForecastTTL, "age after which forecasts are marked stale")
+	fs.DurationVar(&c.ForecastMaxStale, "forecast-max-stale", c.ForecastMaxStale, "age after which forecasts are no longer served")
+
+	fs.IntVar(&c.RateBurst, "rate-burst", c.RateBurst, "requests a client may send at once")
+	fs.IntVar(&c.RatePerMinute, "rate-per-minute", c.RatePerMinute, "requests per minute a client's budget refills by")
+	fs.StringVar(&c.TrustedProxies, "trusted-proxies", c.TrustedProxies, "comma separated proxy IPs/CIDRs whose X-Forwarded-For is trusted")
+	fs.BoolVar(&c.AnonymousRead, "anonymous-read", c.AnonymousRead, "allow read endpoints without an API key")
+
+	fs.StringVar(&c.HistoryStore, "history-store", c.HistoryStore, "history store: disk or memory")
+	fs.DurationVar(&c.HistoryRaw, "history-raw", c.HistoryRaw, "how long raw observations are kept")
+	fs.DurationVar(&c.HistoryHourly, "history-hourly", c.HistoryHourly, "how long hourly rollups are kept")
+	fs.DurationVar(&c.HistoryDaily, "history-daily", c.HistoryDaily, "how long daily rollups are kept")
+	fs.DurationVar(&c.TrendWindow, "trend-window", c.TrendWindow, "history window for trend fits")
+
+	fs.BoolVar(&c.NoBrowser, "no-browser", c.NoBrowser, "do not open the dashboard in a browser")
+	return fs
+}
+
+// envName maps a flag name to its environment variable
+func envName(flagName string) string {
+	return "WEATHER_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// fileKey maps a flag name to its config file key
+func fileKey(flagName string) string {
+	return strings.ReplaceAll(flagName, "-", "_")
+}
+
+// loadConfigFile applies a JSON object of settings, e.g. {"listen": ":9000", "rate_burst": 50}
+func loadConfigFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// Numbers stay in their literal form: fmt.Sprint of a float64 turns 2000000 into 2e+06
+	var settings map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&settings); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("invalid config file %s: unexpected data after the settings object", path)
+	}
+	var errs []error
+	for key, value := range settings {
+		name := strings.ReplaceAll(key, "_", "-")
+		if fs.Lookup(name) == nil {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", path, key))
+			continue
+		}
+		if err := fs.Set(name, fmt.Sprint(value)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", path, key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// loadConfig resolves the configuration from args, the environment and the config file.
+// It returns the arguments left after the flags, such as a "keys" subcommand.
+func loadConfig(args []string) (Config, []string, error) {
+	// Parse flags on their own first; they win, but are applied last
+	fromFlags := defaultConfig()
+	flags := configFlags(&fromFlags, os.Stderr)
+	configPath := flags.String("config", os.Getenv("WEATHER_CONFIG"), "JSON config file (WEATHER_CONFIG)")
+	printConfig := flags.Bool("print-config", false, "print the effective configuration and exit")
+	if err := flags.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	cfg := defaultConfig()
+	cfg.ConfigFile, cfg.PrintConfig = *configPath, *printConfig
+	settings := configFlags(&cfg, io.Discard)
+	if cfg.ConfigFile != "" {
+		if err := loadConfigFile(settings, cfg.ConfigFile); err != nil {
+			return Config{}, nil, err
+		}
+	}
+
+	var errs []error
+	settings.VisitAll(func(f *flag.Flag) {
+		if v, ok := os.LookupEnv(envName(f.Name)); ok && v != "" {
+			if err := settings.Set(f.Name, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", envName(f.Name), err))
+			}
+		}
+	})
+	flags.Visit(func(f *flag.Flag) {
+		if settings.Lookup(f.Name) != nil {
+			settings.Set(f.Name, f.Value.String())
+		}
+	})
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, nil, err
+	}
+	if cfg.KeysFile == "" {
+		cfg.KeysFile = filepath.Join(cfg.DataDir, "apikeys.json")
+	}
+	return cfg, flags.Args(), cfg.validate()
+}
+
+// validate rejects settings the server cannot run with
+func (c Config) validate() error {
+	var errs []error
+	for name, d := range map[string]time.Duration{
+		"refresh-interval": c.RefreshInterval, "api-timeout": c.APITimeout,
+		"forecast-ttl": c.ForecastTTL, "forecast-max-stale": c.ForecastMaxStale,
+		"history-raw": c.HistoryRaw, "history-hourly": c.HistoryHourly, "trend-window": c.TrendWindow,
+	} {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", name))
+		}
+	}
+	if c.HistoryDaily < 0 {
+		errs = append(errs, errors.New("history-daily must not be negative"))
+	}
+	if c.RateBurst < 1 || c.RatePerMinute < 1 {
+		errs = append(errs, errors.New("rate-burst and rate-per-minute must be at least 1"))
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		errs = append(errs, fmt.Errorf("timezone: %w", err))
+	}
+	if c.Listen == "" {
+		errs = append(errs, errors.New("listen must not be empty"))
+	}
+	return errors.Join(errs...)
+}
+
+// apply copies the configuration into the package settings the rest of the server reads
+func (c Config) apply() {
+	CacheRefreshInterval = c.RefreshInterval
+	APITimeout = c.APITimeout
+	DefaultTimezone = c.Timezone
+	cityRegistryPath = c.CitiesFile
+	apiKeys.path = c.KeysFile
+
+	ForecastCacheTTL = c.ForecastTTL
+	ForecastMaxStale = c.ForecastMaxStale
+
+	RateLimitBurst = c.RateBurst
+	RateLimitPerMinute = c.RatePerMinute
+	requestLimiter = newClientLimiter(c.RateBurst, c.RatePerMinute)
+	trustedProxies = parseTrustedProxies(c.TrustedProxies)
+	AnonymousRead = c.AnonymousRead
+
+	HistoryRawRetention = c.HistoryRaw
+	HistoryHourlyRetention = c.HistoryHourly
+	HistoryDailyRetention = c.HistoryDaily
+	TrendWindow = c.TrendWindow
+}
+
+// print writes the effective configuration as a config file would hold it
+func (c Config) print(w io.Writer) error {
+	settings := make(map[string]any)
+	configFlags(&c, io.Discard).VisitAll(func(f *flag.Flag) {
+		switch v := f.Value.(flag.Getter).Get().(type) {
+		case time.Duration:
+			settings[fileKey(f.Name)] = v.String()
+		default:
+			settings[fileKey(f.Name)] = v
+		}
+	})
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// dashboardURL turns a listen address such as ":8081" into a URL to open locally
+func dashboardURL(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "http://" + listen
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// openBrowser shows the dashboard in the desktop's default browser
+func openBrowser(url string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("explorer.exe", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err == nil {
+		go cmd.Wait()
+	}
+}
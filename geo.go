@@ -0,0 +1,254 @@
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	earthRadiusKm   = 6371.0
+	CoastalRadiusKm = 25 // coordinate lookups this close to a coastal city get its local winds
+)
+
+// NearestCity describes the closest registry city to a coordinate
+type NearestCity struct {
+	Key        string
+	Name       string
+	DistanceKm float64
+}
+
+// CoordinateWeather is the response for a lookup by latitude and longitude
+type CoordinateWeather struct {
+	WeatherForecast
+	Latitude    float64
+	Longitude   float64
+	NearestCity *NearestCity
+
+	daily    []DailyConditions // kept to build the forecast in other languages and lengths
+	location *time.Location
+}
+
+// haversineKm returns the great-circle distance between two coordinates
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// nearestCity returns the registry city closest to a coordinate
+func nearestCity(lat, lon float64) *NearestCity {
+	cityLock.RLock()
+	defer cityLock.RUnlock()
+
+	var best *NearestCity
+	for key, coords := range cityCoordinates {
+		d := haversineKm(lat, lon, coords.Latitude, coords.Longitude)
+		if best == nil || d < best.DistanceKm {
+			best = &NearestCity{Key: key, Name: coords.Name, DistanceKm: d}
+		}
+	}
+	if best != nil {
+		best.DistanceKm = math.Round(best.DistanceKm*10) / 10
+	}
+	return best
+}
+
+// coordEntry is one item in the coordinate LRU
+type coordEntry struct {
+	key     string
+	value   *CoordinateWeather
+	expires time.Time
+}
+
+// coordLRU is a bounded LRU cache with a TTL for coordinate lookups
+type coordLRU struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+func newCoordLRU(capacity int, ttl time.Duration) *coordLRU {
+	return &coordLRU{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns a cached value that has not expired
+func (c *coordLRU) Get(key string) (*CoordinateWeather, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*coordEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Put stores a value, evicting the least recently used entry when full
+func (c *coordLRU) Put(key string, value *CoordinateWeather) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		elem.Value = &coordEntry{key: key, value: value, expires: time.Now().Add(c.ttl)}
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&coordEntry{key: key, value: value, expires: time.Now().Add(c.ttl)})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*coordEntry).key)
+	}
+}
+
+// Cache for coordinate lookups, keyed by coordinates rounded to ~1 km
+var coordCache = newCoordLRU(CoordCacheSize, CoordCacheTTL)
+
+// parseCoordinates reads and validates lat/lon query parameters
+func parseCoordinates(r *http.Request) (float64, float64, error) {
+	lat, err := strconv.ParseFloat(r.URL.Query().Get("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, fmt.Errorf("lat must be a number between -90 and 90")
+	}
+	lon, err := strconv.ParseFloat(r.URL.Query().Get("lon"), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("lon must be a number between -180 and 180")
+	}
+	return lat, lon, nil
+}
+
+// fetchCoordinateWeather fetches weather for an arbitrary coordinate through the active provider
+func fetchCoordinateWeather(lat, lon float64) (*CoordinateWeather, error) {
+	log.Printf("Fetching weather data for coordinates (%.2f, %.2f) from %s", lat, lon, weatherProvider.Name())
+
+	query := WeatherQuery{
+		Latitude:  lat,
+		Longitude: lon,
+		Timezone:  inferTimezone(lat, lon),
+	}
+	source, fallback := weatherProvider.Name(), false
+	report, err := weatherProvider.Fetch(query)
+	if err != nil {
+		log.Printf("⚠️ Coordinate fetch failed for (%.2f, %.2f): %v. Using fallback data.", lat, lon, err)
+		report, err = fallbackProvider.Fetch(query)
+		if err != nil {
+			return nil, err
+		}
+		source, fallback = fallbackProvider.Name(), true
+	}
+
+	nearest := nearestCity(lat, lon)
+	coords := CityCoordinates{
+		Name:      fmt.Sprintf("%.2f, %.2f", lat, lon),
+		Latitude:  lat,
+		Longitude: lon,
+		Timezone:  query.Timezone,
+	}
+	// Sites close to a coastal registry city share its wind regimes
+	if nearest != nil && nearest.DistanceKm <= CoastalRadiusKm {
+		if city, ok := lookupCity(nearest.Key); ok {
+			coords.Coastal = city.Coastal
+		}
+	}
+	current := weatherDataFromReport(coords, report)
+	loc := cityLocation(coords)
+
+	return &CoordinateWeather{
+		WeatherForecast: WeatherForecast{
+			Current:  *current,
+			Forecast: forecastFromDaily(report.Daily, DefaultForecastDays, DefaultLanguage, loc),
+			AsciiArt: current.Description,
+			Source:   source,
+			Fallback: fallback,
+			Units:    metricUnits,
+			Language: DefaultLanguage,
+			Timezone: loc.String(),
+			Updated:  localTimestamp(time.Now(), loc),
+		},
+		Latitude:    lat,
+		Longitude:   lon,
+		NearestCity: nearest,
+		daily:       report.Daily,
+		location:    loc,
+	}, nil
+}
+
+// Handler for /api/weather?lat=..&lon=.. - weather for any coordinate
+func coordinateWeatherHandler(w http.ResponseWriter, r *http.Request) {
+	// Rate limiting
+	if !rateLimit(w, r) {
+		return
+	}
+
+	lat, lon, err := parseCoordinates(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	units, ok := requestUnits(w, r)
+	if !ok {
+		return
+	}
+	lang, ok := requestLanguage(w, r)
+	if !ok {
+		return
+	}
+	days, ok := requestForecastDays(w, r)
+	if !ok {
+		return
+	}
+
+	// Round so nearby requests share one upstream call
+	lat = math.Round(lat*100) / 100
+	lon = math.Round(lon*100) / 100
+	key := fmt.Sprintf("%.2f,%.2f", lat, lon)
+
+	result, ok := coordCache.Get(key)
+	if !ok {
+		result, err = fetchCoordinateWeather(lat, lon)
+		if err != nil {
+			log.Printf("⚠️ Coordinate lookup failed for %s: %v", key, err)
+			writeJSONError(w, http.StatusBadGateway, "Weather upstream unavailable")
+			return
+		}
+		coordCache.Put(key, result)
+	}
+
+	// Cached results are shared, so localise and convert a copy
+	body := *result
+	if lang != DefaultLanguage {
+		body.Current = lang.weather(result.Current)
+		body.Language = lang
+	}
+	if lang != DefaultLanguage || days != DefaultForecastDays {
+		body.Forecast = forecastFromDaily(result.daily, days, lang, result.location)
+	}
+	body.WeatherForecast = units.forecast(body.WeatherForecast)
+	setCommonHeaders(w)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// History retention; a daily retention of 0 keeps daily rollups forever
+var (
+	HistoryRawRetention    = 7 * 24 * time.Hour
+	HistoryHourlyRetention = 90 * 24 * time.Hour
+	HistoryDailyRetention  = 5 * 365 * 24 * time.Hour
+)
+
+const HistoryCompactInterval = time.Hour
+
+// Resolution selects raw observations or one of the rollup levels
+type Resolution string
+
+const (
+	ResolutionRaw    Resolution = "raw"
+	ResolutionHourly Resolution = "hourly"
+	ResolutionDaily  Resolution = "daily"
+)
+
+// Observation is one recorded set of weather values for a city
+type Observation struct {
+	Time   time.Time          `json:"t"`
+	Values map[string]float64 `json:"v"`
+}
+
+// Rollup aggregates the observations of one hour or one day
+type Rollup struct {
+	Start  time.Time          `json:"t"`
+	Count  int                `json:"n"`
+	Counts map[string]int     `json:"c,omitempty"` // samples per field; older rollups only have Count
+	Avg    map[string]float64 `json:"avg"`
+	Min    map[string]float64 `json:"min"`
+	Max    map[string]float64 `json:"max"`
+}
+
+// HistoryStore keeps observations per city and downsamples old data into rollups
+type HistoryStore interface {
+	Append(city string, obs Observation) error
+	// Observations returns raw observations in [from, to), oldest first
+	Observations(city string, from, to time.Time) ([]Observation, error)
+	// Rollups returns hourly or daily rollups starting in [from, to), oldest first
+	Rollups(city string, res Resolution, from, to time.Time) ([]Rollup, error)
+	// Compact rolls up data past its retention and deletes what has expired
+	Compact(now time.Time) error
+	// Forget deletes everything recorded for a city that left the registry
+	Forget(city string) error
+	Close() error
+}
+
+var historyStore HistoryStore = newMemoryHistoryStore()
+
+// newHistoryStore builds a history store by name (disk or memory)
+func newHistoryStore(kind, dataDir string) (HistoryStore, error) {
+	switch kind {
+	case "", "disk":
+		return newDiskHistoryStore(dataDir)
+	case "memory":
+		return newMemoryHistoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown history store: %s", kind)
+	}
+}
+
+// observationFromWeather captures every numeric field of WeatherData. Readings that
+// WeatherData rounds to whole numbers are taken from the provider's current conditions.
+func observationFromWeather(data WeatherData, current CurrentConditions, at time.Time) Observation {
+	feels := feelsLike(current.Temperature, current.Humidity, current.WindSpeed)
+	if current.ApparentTemperature != nil {
+		feels = *current.ApparentTemperature
+	}
+	gusts := float64(data.WindGusts)
+	if current.WindGusts != nil {
+		gusts = *current.WindGusts
+	}
+	o := Observation{
+		Time: at.UTC(),
+		Values: map[string]float64{
+			"temperature":   current.Temperature,
+			"feels_like":    feels,
+			"humidity":      float64(current.Humidity),
+			"wind_speed":    current.WindSpeed,
+			"wind_gusts":    gusts,
+			"uv_index":      data.UVIndex,
+			"precip_chance": float64(data.PrecipChance),
+		},
+	}
+	// Directions are stored as a unit vector: averaging degrees would put 350° and 10° at 180°
+	rad := float64(current.WindDirection) * math.Pi / 180
+	o.Values["wind_dir_x"], o.Values["wind_dir_y"] = math.Sin(rad), math.Cos(rad)
+	if data.Pressure > 0 {
+		o.Values["pressure"] = data.Pressure
+	}
+	if data.SurfacePressure > 0 {
+		o.Values["surface_pressure"] = data.SurfacePressure
+	}
+	extended := map[string]*float64{
+		"dew_point":     data.DewPoint,
+		"visibility":    data.Visibility,
+		"precipitation": data.Precipitation,
+		"rain":          data.Rain,
+		"snowfall":      data.Snowfall,
+		"snow_depth":    data.SnowDepth,
+	}
+	for name, v := range extended {
+		if v != nil {
+			o.Values[name] = *v
+		}
+	}
+	if data.CloudCover != nil {
+		o.Values["cloud_cover"] = float64(*data.CloudCover)
+	}
+	return o
+}
+
+// recordHistory stores an observation for trend analysis and reporting
+func recordHistory(city string, data WeatherData, current CurrentConditions, at time.Time) {
+	if err := historyStore.Append(city, observationFromWeather(data, current, at)); err != nil {
+		log.Printf("⚠️ Failed to record history for %s: %v", city, err)
+	}
+}
+
+// historySeries returns everything recorded for a city in [from, to) as rollups.
+// Old data is only available at the resolution it was compacted to.
+func historySeries(city string, from, to time.Time) ([]Rollup, error) {
+	daily, err := historyStore.Rollups(city, ResolutionDaily, from, to)
+	if err != nil {
+		return nil, err
+	}
+	hourly, err := historyStore.Rollups(city, ResolutionHourly, from, to)
+	if err != nil {
+		return nil, err
+	}
+	obs, err := historyStore.Observations(city, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	series := append(daily, hourly...)
+	for _, o := range obs {
+		series = append(series, rollupOf(o, o.Time))
+	}
+	return series, nil
+}
+
+// runHistoryCompaction downsamples and expires history until ctx is cancelled
+func runHistoryCompaction(ctx context.Context) {
+	ticker := time.NewTicker(HistoryCompactInterval)
+	defer ticker.Stop()
+	for {
+		if err := historyStore.Compact(time.Now()); err != nil {
+			log.Printf("⚠️ History compaction failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// bucketStart returns the start of the local hour or day containing t
+func bucketStart(t time.Time, res Resolution, loc *time.Location) time.Time {
+	if res == ResolutionDaily {
+		return bucketStartIn(t, 24*time.Hour, loc)
+	}
+	return bucketStartIn(t, time.Hour, loc)
+}
+
+// historyLocation returns the timezone a city's history is rolled up in
+func historyLocation(city string) *time.Location {
+	coords, _ := lookupCity(city)
+	return cityLocation(coords)
+}
+
+// rollupOf turns a single observation into a one-sample rollup
+func rollupOf(obs Observation, start time.Time) Rollup {
+	r := Rollup{
+		Start: start,
+		Count: 1,
+		Avg:   make(map[string]float64, len(obs.Values)),
+		Min:   make(map[string]float64, len(obs.Values)),
+		Max:   make(map[string]float64, len(obs.Values)),
+	}
+	for k, v := range obs.Values {
+		r.Avg[k], r.Min[k], r.Max[k] = v, v, v
+	}
+	return r
+}
+
+// samples returns how many samples of field k a rollup holds
+func (r Rollup) samples(k string) int {
+	if n, ok := r.Counts[k]; ok {
+		return n
+	}
+	return r.Count
+}
+
+// mergeRollup folds b into a, weighting each field's average by its own sample count,
+// since not every observation has every field
+func mergeRollup(a *Rollup, b Rollup) {
+	if a.Counts == nil {
+		a.Counts = make(map[string]int, len(a.Avg))
+		for k := range a.Avg {
+			a.Counts[k] = a.Count
+		}
+	}
+	for k, v := range b.Avg {
+		nb := b.samples(k)
+		if _, ok := a.Avg[k]; !ok {
+			a.Avg[k], a.Min[k], a.Max[k], a.Counts[k] = v, b.Min[k], b.Max[k], nb
+			continue
+		}
+		na := a.Counts[k]
+		a.Avg[k] = (a.Avg[k]*float64(na) + v*float64(nb)) / float64(na+nb)
+		a.Min[k] = math.Min(a.Min[k], b.Min[k])
+		a.Max[k] = math.Max(a.Max[k], b.Max[k])
+		a.Counts[k] = na + nb
+	}
+	a.Count += b.Count
+}
+
+// aggregate groups rollups (or observations turned into rollups) into buckets of res in loc
+func aggregate(items []Rollup, res Resolution, loc *time.Location) []Rollup {
+	return aggregateBy(items, func(t time.Time) time.Time { return bucketStart(t, res, loc) })
+}
+
+// aggregateBy merges rollups that share a bucket, as assigned by bucket, oldest first
+func aggregateBy(items []Rollup, bucket func(time.Time) time.Time) []Rollup {
+	byStart := make(map[time.Time]*Rollup)
+	for _, item := range items {
+		start := bucket(item.Start)
+		if r, ok := byStart[start]; ok {
+			mergeRollup(r, item)
+			continue
+		}
+		r := Rollup{
+			Start: start,
+			Avg:   make(map[string]float64),
+			Min:   make(map[string]float64),
+			Max:   make(map[string]float64),
+		}
+		mergeRollup(&r, item)
+		byStart[start] = &r
+	}
+
+	result := make([]Rollup, 0, len(byStart))
+	for _, r := range byStart {
+		result = append(result, *r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Start.Before(result[j].Start) })
+	return result
+}
+
+// observationsToRollups aggregates raw observations into buckets of res in loc
+func observationsToRollups(obs []Observation, res Resolution, loc *time.Location) []Rollup {
+	items := make([]Rollup, len(obs))
+	for i, o := range obs {
+		items[i] = rollupOf(o, o.Time)
+	}
+	return aggregate(items, res, loc)
+}
+
+// HistoricalData stores the in-memory history of one city
+type HistoricalData struct {
+	Location     string
+	Observations []Observation
+	Hourly       []Rollup
+	Daily        []Rollup
+	Mutex        sync.RWMutex
+}
+
+// memoryHistoryStore keeps history in memory only; it is lost on restart
+type memoryHistoryStore struct {
+	mu   sync.RWMutex
+	data map[string]*HistoricalData
+}
+
+func newMemoryHistoryStore() *memoryHistoryStore {
+	return &memoryHistoryStore{data: make(map[string]*HistoricalData)}
+}
+
+func (s *memoryHistoryStore) series(city string, create bool) *HistoricalData {
+	s.mu.RLock()
+	hist, ok := s.data[city]
+	s.mu.RUnlock()
+	if ok || !create {
+		return hist
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if hist, ok = s.data[city]; !ok {
+		hist = &HistoricalData{Location: city}
+		s.data[city] = hist
+	}
+	return hist
+}
+
+func (s *memoryHistoryStore) Append(city string, obs Observation) error {
+	hist := s.series(city, true)
+	hist.Mutex.Lock()
+	defer hist.Mutex.Unlock()
+	hist.Observations = append(hist.Observations, obs)
+	return nil
+}
+
+func (s *memoryHistoryStore) Observations(city string, from, to time.Time) ([]Observation, error) {
+	hist := s.series(city, false)
+	if hist == nil {
+		return nil, nil
+	}
+	hist.Mutex.RLock()
+	defer hist.Mutex.RUnlock()
+
+	var result []Observation
+	for _, o := range hist.Observations {
+		if !o.Time.Before(from) && o.Time.Before(to) {
+			result = append(result, o)
+		}
+	}
+	return result, nil
+}
+
+func (s *memoryHistoryStore) Rollups(city string, res Resolution, from, to time.Time) ([]Rollup, error) {
+	hist := s.series(city, false)
+	if hist == nil {
+		return nil, nil
+	}
+	hist.Mutex.RLock()
+	defer hist.Mutex.RUnlock()
+
+	source := hist.Hourly
+	if res == ResolutionDaily {
+		source = hist.Daily
+	}
+	var result []Rollup
+	for _, r := range source {
+		if !r.Start.Before(from) && r.Start.Before(to) {
+			result = append(result, r)
+		}
+	}
+	return result, nil
+}
+
+func (s *memoryHistoryStore) Compact(now time.Time) error {
+	s.mu.RLock()
+	all := make([]*HistoricalData, 0, len(s.data))
+	for _, hist := range s.data {
+		all = append(all, hist)
+	}
+	s.mu.RUnlock()
+
+	for _, hist := range all {
+		loc := historyLocation(hist.Location)
+		rawCutoff := bucketStart(now.Add(-HistoryRawRetention), ResolutionHourly, loc)
+		hourlyCutoff := bucketStart(now.Add(-HistoryHourlyRetention), ResolutionDaily, loc)
+
+		hist.Mutex.Lock()
+		keep := 0
+		var old []Observation
+		for _, o := range hist.Observations {
+			if o.Time.Before(rawCutoff) {
+				old = append(old, o)
+			} else {
+				hist.Observations[keep] = o
+				keep++
+			}
+		}
+		hist.Observations = hist.Observations[:keep]
+		hist.Hourly = aggregate(append(hist.Hourly, observationsToRollups(old, ResolutionHourly, loc)...), ResolutionHourly, loc)
+
+		var oldHourly, hourly []Rollup
+		for _, r := range hist.Hourly {
+			if r.Start.Before(hourlyCutoff) {
+				oldHourly = append(oldHourly, r)
+			} else {
+				hourly = append(hourly, r)
+			}
+		}
+		hist.Hourly = hourly
+		hist.Daily = aggregate(append(hist.Daily, oldHourly...), ResolutionDaily, loc)
+
+		if HistoryDailyRetention > 0 {
+			dailyCutoff := now.Add(-HistoryDailyRetention)
+			var daily []Rollup
+			for _, r := range hist.Daily {
+				if !r.Start.Before(dailyCutoff) {
+					daily = append(daily, r)
+				}
+			}
+			hist.Daily = daily
+		}
+		hist.Mutex.Unlock()
+	}
+	return nil
+}
+
+func (s *memoryHistoryStore) Forget(city string) error {
+	s.mu.Lock()
+	delete(s.data, city)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memoryHistoryStore) Close() error { return nil }
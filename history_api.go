@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultHistoryWindow = 24 * time.Hour
+	DefaultHistoryStep   = time.Hour
+	MinHistoryStep       = time.Minute
+	MaxHistoryPoints     = 10000 // buckets per request
+)
+
+// HistoryPoint is one time bucket of /api/history; fields without samples are null
+type HistoryPoint struct {
+	Time        string // RFC 3339 in the city's timezone, start of the bucket
+	Samples     int
+	Temperature *float64
+	Humidity    *float64
+	WindSpeed   *float64
+	Pressure    *float64 // sea level
+}
+
+// HistoryResponse is the body of /api/history/<city>
+type HistoryResponse struct {
+	Location    string
+	Timezone    string
+	From        string
+	To          string
+	Step        string
+	Aggregation string
+	Trend       string // current temperature trend: rising, falling or stable
+	Units       Units
+	Points      []HistoryPoint
+}
+
+// parseHistoryTime accepts RFC 3339 timestamps or plain dates (midnight in loc)
+func parseHistoryTime(v string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", v, loc)
+}
+
+// parseHistoryStep accepts Go durations such as "15m" or "6h", plus whole days such as "1d"
+func parseHistoryStep(v string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(v)
+}
+
+// historyPoints buckets a series by step in loc and picks the requested aggregate of each field
+func historyPoints(series []Rollup, step time.Duration, agg string, loc *time.Location) []HistoryPoint {
+	buckets := aggregateBy(series, func(t time.Time) time.Time { return bucketStartIn(t, step, loc) })
+
+	points := make([]HistoryPoint, 0, len(buckets))
+	for _, b := range buckets {
+		values := b.Avg
+		switch agg {
+		case "min":
+			values = b.Min
+		case "max":
+			values = b.Max
+		}
+		field := func(name string) *float64 {
+			v, ok := values[name]
+			if !ok {
+				return nil
+			}
+			v = math.Round(v*10) / 10
+			return &v
+		}
+		points = append(points, HistoryPoint{
+			Time:        b.Start.Format(time.RFC3339),
+			Samples:     b.Count,
+			Temperature: field("temperature"),
+			Humidity:    field("humidity"),
+			WindSpeed:   field("wind_speed"),
+			Pressure:    field("pressure"),
+		})
+	}
+	return points
+}
+
+// writeHistoryCSV writes points as a spreadsheet-friendly CSV file
+func writeHistoryCSV(w http.ResponseWriter, city string, points []HistoryPoint, units Units) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-history.csv"`, city))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "samples", "temperature (" + units.Temperature + ")", "humidity (%)", "wind_speed (" + units.WindSpeed + ")", "pressure (" + units.Pressure + ")"})
+	for _, p := range points {
+		record := []string{p.Time, strconv.Itoa(p.Samples)}
+		for _, v := range []*float64{p.Temperature, p.Humidity, p.WindSpeed, p.Pressure} {
+			if v == nil {
+				record = append(record, "")
+			} else {
+				record = append(record, strconv.FormatFloat(*v, 'f', -1, 64))
+			}
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+}
+
+// Handler for /api/history/<city>?from=&to=&step=1h&agg=avg|min|max[&format=csv]
+func historyAPIHandler(w http.ResponseWriter, r *http.Request) {
+	// Rate limiting
+	if !rateLimit(w, r) {
+		return
+	}
+
+	location := strings.ToLower(strings.TrimPrefix(r.URL.Path, "/api/history/"))
+	coords, ok := lookupCity(location)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Location not found")
+		return
+	}
+
+	loc := cityLocation(coords)
+	q := r.URL.Query()
+	to := time.Now().In(loc)
+	if v := q.Get("to"); v != "" {
+		t, err := parseHistoryTime(v, loc)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "to must be an RFC 3339 time or a YYYY-MM-DD date")
+			return
+		}
+		to = t
+	}
+	from := to.Add(-DefaultHistoryWindow)
+	if v := q.Get("from"); v != "" {
+		t, err := parseHistoryTime(v, loc)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "from must be an RFC 3339 time or a YYYY-MM-DD date")
+			return
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		writeJSONError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	step, stepLabel := DefaultHistoryStep, "1h"
+	if v := q.Get("step"); v != "" {
+		d, err := parseHistoryStep(v)
+		if err != nil || d < MinHistoryStep {
+			writeJSONError(w, http.StatusBadRequest, "step must be a duration of at least 1m, e.g. 15m, 1h or 1d")
+			return
+		}
+		step, stepLabel = d, v
+	}
+	if to.Sub(from)/step > MaxHistoryPoints {
+		writeJSONError(w, http.StatusBadRequest, "Too many points, use a larger step or a shorter window")
+		return
+	}
+
+	agg := q.Get("agg")
+	switch agg {
+	case "":
+		agg = "avg"
+	case "avg", "min", "max":
+	default:
+		writeJSONError(w, http.StatusBadRequest, "agg must be avg, min or max")
+		return
+	}
+
+	units, ok := requestUnits(w, r)
+	if !ok {
+		return
+	}
+
+	series, err := historySeries(location, from, to)
+	if err != nil {
+		log.Printf("⚠️ Failed to read history for %s: %v", location, err)
+		writeJSONError(w, http.StatusInternalServerError, "History unavailable")
+		return
+	}
+	points := historyPoints(series, step, agg, loc)
+	units.historyPoints(points)
+
+	if q.Get("format") == "csv" || strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		writeHistoryCSV(w, location, points, units)
+		return
+	}
+
+	setCommonHeaders(w)
+	json.NewEncoder(w).Encode(HistoryResponse{
+		Location:    coords.Name,
+		Timezone:    loc.String(),
+		From:        localTimestamp(from, loc),
+		To:          localTimestamp(to, loc),
+		Step:        stepLabel,
+		Aggregation: agg,
+		Trend:       getTemperatureTrend(location),
+		Units:       units,
+		Points:      points,
+	})
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// diskHistoryStore keeps append-only JSON-lines segments per city, named by dates in the
+// city's timezone so daily rollups start at local midnight:
+//
+//	history/<city>/raw-2006-01-02.jsonl     observations of one local day
+//	history/<city>/hourly-2006-01-02.jsonl  hourly rollups of one local day
+//	history/<city>/daily-2006-01.jsonl      daily rollups of one local month
+//
+// Compact turns raw days past retention into hourly segments, hourly days
+// into daily segments, and deletes daily segments past their retention.
+type diskHistoryStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+func newDiskHistoryStore(dataDir string) (*diskHistoryStore, error) {
+	dir := filepath.Join(dataDir, "history")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	return &diskHistoryStore{dir: dir}, nil
+}
+
+func (s *diskHistoryStore) segment(city, kind, period string) string {
+	return filepath.Join(s.dir, city, kind+"-"+period+".jsonl")
+}
+
+func (s *diskHistoryStore) Append(city string, obs Observation) error {
+	line, err := json.Marshal(obs)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Join(s.dir, city), 0755); err != nil {
+		return err
+	}
+	day := obs.Time.In(historyLocation(city)).Format("2006-01-02")
+	f, err := os.OpenFile(s.segment(city, "raw", day), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readLines decodes every line of a segment, skipping lines a crash may have cut short
+func readLines[T any](path string) ([]T, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var items []T
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var item T
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			log.Printf("⚠️ Skipping corrupt line in %s: %v", path, err)
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, scanner.Err()
+}
+
+// writeLines replaces a segment atomically
+func writeLines[T any](path string, items []T) error {
+	var b strings.Builder
+	for _, item := range items {
+		line, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// localDays lists the local days touching [from, to), plus one on either side for
+// segments written before the city's timezone was used (UTC days)
+func localDays(from, to time.Time, loc *time.Location) []time.Time {
+	var result []time.Time
+	for d := bucketStart(from, ResolutionDaily, loc).AddDate(0, 0, -1); d.Before(to.AddDate(0, 0, 1)); d = d.AddDate(0, 0, 1) {
+		result = append(result, d)
+	}
+	return result
+}
+
+func (s *diskHistoryStore) Observations(city string, from, to time.Time) ([]Observation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []Observation
+	for _, day := range localDays(from, to, historyLocation(city)) {
+		items, err := readLines[Observation](s.segment(city, "raw", day.Format("2006-01-02")))
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range items {
+			if !o.Time.Before(from) && o.Time.Before(to) {
+				result = append(result, o)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *diskHistoryStore) Rollups(city string, res Resolution, from, to time.Time) ([]Rollup, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	loc := historyLocation(city)
+	var paths []string
+	if res == ResolutionDaily {
+		start := from.In(loc).AddDate(0, 0, -1)
+		for m := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, loc); m.Before(to); m = m.AddDate(0, 1, 0) {
+			paths = append(paths, s.segment(city, "daily", m.Format("2006-01")))
+		}
+	} else {
+		for _, day := range localDays(from, to, loc) {
+			paths = append(paths, s.segment(city, "hourly", day.Format("2006-01-02")))
+		}
+	}
+
+	var result []Rollup
+	for _, path := range paths {
+		items, err := readLines[Rollup](path)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range items {
+			if !r.Start.Before(from) && r.Start.Before(to) {
+				result = append(result, r)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *diskHistoryStore) Compact(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cities, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, c := range cities {
+		if c.IsDir() {
+			if err := s.compactCity(c.Name(), now); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", c.Name(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// compactCity downsamples and expires one city's segments; the caller holds s.mu
+func (s *diskHistoryStore) compactCity(city string, now time.Time) error {
+	entries, err := os.ReadDir(filepath.Join(s.dir, city))
+	if err != nil {
+		return err
+	}
+	// Process raw before hourly so a day can move through both levels in one pass
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() > entries[j].Name() })
+
+	rawCutoff := now.Add(-HistoryRawRetention)
+	hourlyCutoff := now.Add(-HistoryHourlyRetention)
+	loc := historyLocation(city)
+
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".jsonl")
+		kind, period, ok := strings.Cut(name, "-")
+		if !ok || e.IsDir() || !strings.HasSuffix(e.Name(), ".jsonl") {
+			continue
+		}
+		path := filepath.Join(s.dir, city, e.Name())
+
+		switch kind {
+		case "raw":
+			day, err := time.ParseInLocation("2006-01-02", period, loc)
+			if err != nil || day.AddDate(0, 0, 1).After(rawCutoff) {
+				continue
+			}
+			obs, err := readLines[Observation](path)
+			if err != nil {
+				return err
+			}
+			// Rewriting the whole day keeps this step idempotent if we crash before the remove
+			if err := writeLines(s.segment(city, "hourly", period), observationsToRollups(obs, ResolutionHourly, loc)); err != nil {
+				return err
+			}
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			if day.AddDate(0, 0, 1).After(hourlyCutoff) {
+				continue
+			}
+			// The new hourly segment is already past its own retention
+			path = s.segment(city, "hourly", period)
+			fallthrough
+
+		case "hourly":
+			day, err := time.ParseInLocation("2006-01-02", period, loc)
+			if err != nil || day.AddDate(0, 0, 1).After(hourlyCutoff) {
+				continue
+			}
+			hourly, err := readLines[Rollup](path)
+			if err != nil {
+				return err
+			}
+			dailyPath := s.segment(city, "daily", day.Format("2006-01"))
+			daily, err := readLines[Rollup](dailyPath)
+			if err != nil {
+				return err
+			}
+			// Replace any rollup of the same day so a retried compaction does not double count.
+			// Segments from before local days were used can spill into a neighbouring day, which is merged.
+			var kept []Rollup
+			for _, r := range daily {
+				if !r.Start.Equal(day) {
+					kept = append(kept, r)
+				}
+			}
+			merged := aggregate(append(kept, hourly...), ResolutionDaily, loc)
+			if err := writeLines(dailyPath, merged); err != nil {
+				return err
+			}
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+
+		case "daily":
+			month, err := time.ParseInLocation("2006-01", period, loc)
+			if err != nil || HistoryDailyRetention <= 0 || month.AddDate(0, 1, 0).After(now.Add(-HistoryDailyRetention)) {
+				continue
+			}
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Forget removes the city's history directory
+func (s *diskHistoryStore) Forget(city string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return os.RemoveAll(filepath.Join(s.dir, city))
+}
+
+// Close waits for in-flight writes; every append is written through, so nothing else needs flushing
+func (s *diskHistoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return nil
+}
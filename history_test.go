@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestDiskCompactionLocalDays checks daily rollups start at the city's local midnight,
+// including for a zone west of UTC where the local day spans two UTC days
+func TestDiskCompactionLocalDays(t *testing.T) {
+	cityLock.Lock()
+	saved := cityCoordinates
+	cityCoordinates = map[string]CityCoordinates{
+		"newyork": {Name: "New York", Latitude: 40.71, Longitude: -74.01, Timezone: "America/New_York"},
+	}
+	cityLock.Unlock()
+	t.Cleanup(func() {
+		cityLock.Lock()
+		cityCoordinates = saved
+		cityLock.Unlock()
+	})
+
+	store, err := newDiskHistoryStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc, _ := time.LoadLocation("America/New_York")
+	for _, local := range []time.Time{
+		time.Date(2026, 1, 10, 1, 0, 0, 0, loc),
+		time.Date(2026, 1, 10, 23, 0, 0, 0, loc), // already 11 January in UTC
+	} {
+		obs := Observation{Time: local.UTC(), Values: map[string]float64{"temperature": 2.5}}
+		if err := store.Append("newyork", obs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.Compact(time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	daily, err := store.Rollups("newyork", ResolutionDaily, time.Date(2026, 1, 1, 0, 0, 0, 0, loc), time.Date(2026, 2, 1, 0, 0, 0, 0, loc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(daily) != 1 {
+		t.Fatalf("got %d daily rollups, want 1: %+v", len(daily), daily)
+	}
+	if want := time.Date(2026, 1, 10, 0, 0, 0, 0, loc); !daily[0].Start.Equal(want) || daily[0].Count != 2 {
+		t.Errorf("daily rollup starts %s with %d samples, want %s with 2", daily[0].Start, daily[0].Count, want)
+	}
+}
+
+// TestAggregateMissingFields checks averages only count the samples that have the field
+func TestAggregateMissingFields(t *testing.T) {
+	start := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	obs := []Observation{
+		{Time: start, Values: map[string]float64{"temperature": 4, "visibility": 10}},
+		{Time: start.Add(10 * time.Minute), Values: map[string]float64{"temperature": 5}},
+		{Time: start.Add(20 * time.Minute), Values: map[string]float64{"temperature": 6, "visibility": 20}},
+	}
+	hourly := observationsToRollups(obs, ResolutionHourly, time.UTC)
+	if len(hourly) != 1 {
+		t.Fatalf("got %d hourly rollups, want 1", len(hourly))
+	}
+	if got := hourly[0].Avg["visibility"]; got != 15 {
+		t.Errorf("hourly visibility average = %v, want 15", got)
+	}
+
+	// A second hour without visibility must not dilute the daily average either
+	later := rollupOf(Observation{Values: map[string]float64{"temperature": 8}}, start.Add(time.Hour))
+	daily := aggregate(append(hourly, later), ResolutionDaily, time.UTC)
+	if got := daily[0].Avg["visibility"]; got != 15 {
+		t.Errorf("daily visibility average = %v, want 15", got)
+	}
+	if got := daily[0].Avg["temperature"]; got != 5.75 {
+		t.Errorf("daily temperature average = %v, want 5.75", got)
+	}
+}
+
+// TestWindDirectionAverage checks directions either side of north average to north, not south
+func TestWindDirectionAverage(t *testing.T) {
+	start := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	var obs []Observation
+	for i, dir := range []int{350, 10} {
+		current := CurrentConditions{WindDirection: dir}
+		obs = append(obs, observationFromWeather(WeatherData{}, current, start.Add(time.Duration(i)*time.Minute)))
+	}
+	avg := observationsToRollups(obs, ResolutionHourly, time.UTC)[0].Avg
+	if x, y := avg["wind_dir_x"], avg["wind_dir_y"]; math.Abs(x) > 1e-9 || y < 0.98 {
+		t.Errorf("average wind direction vector = (%v, %v), want north (0, ~0.985)", x, y)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultHourlyHours = 48
+	MaxHourlyHours     = 168 // one week ahead
+)
+
+// HourlyForecast is one hour of the hourly forecast endpoint
+type HourlyForecast struct {
+	Time          string // RFC 3339 in the city's timezone
+	Day           string
+	Hour          string
+	Temperature   float64
+	PrecipChance  int
+	Precipitation float64 // mm
+	WindSpeed     float64 // km/h
+	WindDirection int     // degrees, where the wind comes from
+	WindGusts     float64 // km/h
+	ConditionID   Condition
+	Condition     string
+	Intensity     Intensity
+	IsDay         bool
+	Emoji         string
+}
+
+// HourlyResponse is the body of /api/hourly/<city>
+type HourlyResponse struct {
+	Location string
+	Timezone string
+	Hours    []HourlyForecast
+	Source   string
+	Fallback bool
+	Stale    bool
+	Units    Units
+	Language Language
+}
+
+// hourlyFromEntry converts up to hours of cached provider data, starting at the current hour
+func hourlyFromEntry(hourly []HourlyConditions, loc *time.Location, hours int, lang Language) []HourlyForecast {
+	start := bucketStartIn(time.Now(), time.Hour, loc) // local hour, also in :30 and :45 offset zones
+	result := make([]HourlyForecast, 0, hours)
+	for _, h := range hourly {
+		if len(result) >= hours {
+			break
+		}
+		t := h.Time.In(loc)
+		if t.Before(start) {
+			continue
+		}
+		condition, intensity := lookupWMOCode(h.WeatherCode)
+		result = append(result, HourlyForecast{
+			Time:          t.Format(time.RFC3339),
+			Day:           lang.Weekday(t.Weekday()),
+			Hour:          t.Format("15:04"),
+			Temperature:   h.Temperature,
+			PrecipChance:  h.PrecipitationProbability,
+			Precipitation: h.Precipitation,
+			WindSpeed:     h.WindSpeed,
+			WindDirection: h.WindDirection,
+			WindGusts:     h.WindGusts,
+			ConditionID:   condition,
+			Condition:     lang.ConditionName(condition),
+			Intensity:     intensity,
+			IsDay:         h.IsDay,
+			Emoji:         condition.EmojiAt(h.IsDay),
+		})
+	}
+	return result
+}
+
+// Handler for /api/hourly/<city>?hours=N
+func hourlyAPIHandler(w http.ResponseWriter, r *http.Request) {
+	// Rate limiting
+	if !rateLimit(w, r) {
+		return
+	}
+
+	location := strings.ToLower(strings.TrimPrefix(r.URL.Path, "/api/hourly/"))
+	coords, ok := lookupCity(location)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Location not found")
+		return
+	}
+
+	hours := DefaultHourlyHours
+	if v := r.URL.Query().Get("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxHourlyHours {
+			writeJSONError(w, http.StatusBadRequest, "hours must be between 1 and "+strconv.Itoa(MaxHourlyHours))
+			return
+		}
+		hours = n
+	}
+	units, ok := requestUnits(w, r)
+	if !ok {
+		return
+	}
+	lang, ok := requestLanguage(w, r)
+	if !ok {
+		return
+	}
+
+	snap, ok := getCachedWeather(location)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Location not found")
+		return
+	}
+	if snap.isStale(ForecastMaxStale) {
+		writeJSONError(w, http.StatusServiceUnavailable, "Forecast unavailable")
+		return
+	}
+
+	loc := cityLocation(coords)
+	forecast := hourlyFromEntry(snap.Hourly, loc, hours, lang)
+	units.hourly(forecast)
+
+	setCommonHeaders(w)
+	json.NewEncoder(w).Encode(HourlyResponse{
+		Location: coords.Name,
+		Timezone: loc.String(),
+		Hours:    forecast,
+		Source:   snap.Source,
+		Fallback: snap.Fallback,
+		Stale:    snap.isStale(ForecastCacheTTL),
+		Units:    units,
+		Language: lang,
+	})
+}
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Language is a supported response language (ISO 639-1)
+type Language string
+
+const (
+	LangCroatian Language = "hr"
+	LangEnglish  Language = "en"
+	LangGerman   Language = "de"
+)
+
+// DefaultLanguage is used when neither lang= nor Accept-Language picks a catalog
+const DefaultLanguage = LangCroatian
+
+// catalog holds the display text of one language
+type catalog struct {
+	conditions   map[Condition]string
+	messages     map[Condition][]string
+	night        []string // replaces the sunny messages of clear conditions at night
+	winds        map[LocalWind]string
+	windMessages map[LocalWind][]string // replace the condition's messages while a local wind blows
+	alerts       map[AlertKind]string
+	compass      [16]string // clockwise from north
+	weekdays     [7]string  // indexed by time.Weekday, Sunday first
+}
+
+var catalogs = map[Language]catalog{
+	LangCroatian: {
+		conditions: map[Condition]string{
+			ConditionClear:            "Sunčano",
+			ConditionPartlyCloudy:     "Djelomično oblačno",
+			ConditionCloudy:           "Oblačno",
+			ConditionFog:              "Magla",
+			ConditionRain:             "Kišno",
+			ConditionSnow:             "Snježno",
+			ConditionShowers:          "Pljuskovi",
+			ConditionSnowShowers:      "Snježni pljuskovi",
+			ConditionThunderstorm:     "Oluja",
+			ConditionMainlyClear:      "Pretežno vedro",
+			ConditionDrizzle:          "Rosulja",
+			ConditionFreezingDrizzle:  "Ledena rosulja",
+			ConditionFreezingRain:     "Ledena kiša",
+			ConditionSnowGrains:       "Zrnati snijeg",
+			ConditionThunderstormHail: "Oluja s tučom",
+		},
+		messages: map[Condition][]string{
+			ConditionRain: {
+				"Kiša pada - Donesi kišobran!",
+				"Mokri ulazak - Čuva se od kiše!",
+				"Nebo se prazni - Ostani unutar!",
+				"Kiša je ovdje - Bodljikavo vrijeme!",
+			},
+			ConditionClear: {
+				"Sunce sjaji - Divno vrijeme!",
+				"Zaštita od sunca preporučena!",
+				"Najljepši dan godine!",
+				"Idealno za planinu!",
+			},
+			ConditionCloudy: {
+				"Oblaci pokrivaju nebo!",
+				"Blago sive boje - ali ugodno!",
+				"Nema sunca ali nije loše!",
+				"Tipično zimsko vrijeme!",
+			},
+			ConditionPartlyCloudy: {
+				"Mješavina sunca i oblaka!",
+				"Lijepo, ali može biti hladnije!",
+				"Promjenjivo vrijeme!",
+				"Oblaci se pojavljuju i nestaju!",
+			},
+			ConditionSnow: {
+				"Snijeg pada - Zimska čarolija!",
+				"Bijela pokrivka na zemlji!",
+				"Zimski podaci - Odjevite se toplo!",
+				"Snježni pejzaž je spektakularan!",
+			},
+			ConditionFog: {
+				"Magla je gusta - Vozi polako!",
+				"Ne vidi se prst pred okom!",
+			},
+			ConditionShowers: {
+				"Pljusak stiže - Skloni se!",
+				"Kratko ali žestoko!",
+			},
+			ConditionSnowShowers: {
+				"Snježni pljuskovi - Pazi na cestu!",
+				"Snijeg u naletima!",
+			},
+			ConditionThunderstorm: {
+				"Grmljavina - Ostani unutra!",
+				"Munje paraju nebo!",
+			},
+			ConditionMainlyClear: {
+				"Tek pokoji oblak - Uživaj!",
+				"Gotovo savršeno nebo!",
+			},
+			ConditionDrizzle: {
+				"Sitna kišica - Jakna je dovoljna!",
+				"Rosulja ne prestaje!",
+			},
+			ConditionFreezingDrizzle: {
+				"Ledena rosulja - Pločnici su skliski!",
+				"Oprez, led na cesti!",
+			},
+			ConditionFreezingRain: {
+				"Ledena kiša - Ostani doma ako možeš!",
+				"Sve se pretvara u klizalište!",
+			},
+			ConditionSnowGrains: {
+				"Zrnca snijega u zraku!",
+				"Sitan snijeg sipi!",
+			},
+			ConditionThunderstormHail: {
+				"Tuča i grmljavina - Skloni auto!",
+				"Led pada s neba!",
+			},
+		},
+		night: []string{
+			"Vedra noć - Zvijezde su vani!",
+			"Mjesec obasjava grad!",
+		},
+		winds: map[LocalWind]string{
+			WindBura:     "Bura",
+			WindJugo:     "Jugo",
+			WindMaestral: "Maestral",
+		},
+		windMessages: map[LocalWind][]string{
+			WindBura: {
+				"Puše bura - Drži se za ogradu!",
+				"Bura reže do kostiju!",
+			},
+			WindJugo: {
+				"Jugo diže more - Glava boli!",
+				"Puše jugo, stiže kiša!",
+			},
+			WindMaestral: {
+				"Maestral osvježava popodne!",
+				"Idealno za jedrenje!",
+			},
+		},
+		alerts: map[AlertKind]string{
+			AlertGale:         "Olujni vjetar - Osiguraj predmete na otvorenom!",
+			AlertBura:         "Jaka bura - Mogući prekidi prometa na mostovima i trajektima!",
+			AlertJugo:         "Jako jugo - Visoki valovi na otvorenom moru!",
+			AlertThunderstorm: "Grmljavinsko nevrijeme - Izbjegavaj otvoreni prostor!",
+			AlertPressureDrop: "Tlak naglo pada - Moguće nevrijeme, pomorci oprez!",
+		},
+		compass:  [16]string{"S", "SSI", "SI", "ISI", "I", "IJI", "JI", "JJI", "J", "JJZ", "JZ", "ZJZ", "Z", "ZSZ", "SZ", "SSZ"},
+		weekdays: [7]string{"Nedjelja", "Ponedjeljak", "Utorak", "Srijeda", "Četvrtak", "Petak", "Subota"},
+	},
+	LangEnglish: {
+		conditions: map[Condition]string{
+			ConditionClear:            "Sunny",
+			ConditionPartlyCloudy:     "Partly cloudy",
+			ConditionCloudy:           "Cloudy",
+			ConditionFog:              "Fog",
+			ConditionRain:             "Rain",
+			ConditionSnow:             "Snow",
+			ConditionShowers:          "Showers",
+			ConditionSnowShowers:      "Snow showers",
+			ConditionThunderstorm:     "Thunderstorm",
+			ConditionMainlyClear:      "Mainly clear",
+			ConditionDrizzle:          "Drizzle",
+			ConditionFreezingDrizzle:  "Freezing drizzle",
+			ConditionFreezingRain:     "Freezing rain",
+			ConditionSnowGrains:       "Snow grains",
+			ConditionThunderstormHail: "Thunderstorm with hail",
+		},
+		messages: map[Condition][]string{
+			ConditionRain: {
+				"It's raining - Bring an umbrella!",
+				"A wet one out there - Stay dry!",
+				"The sky is emptying - Stay inside!",
+				"Rain is here - Soggy weather!",
+			},
+			ConditionClear: {
+				"The sun is shining - Lovely weather!",
+				"Sun protection recommended!",
+				"The best day of the year!",
+				"Perfect for the mountains!",
+			},
+			ConditionCloudy: {
+				"Clouds cover the sky!",
+				"Softly grey - but pleasant!",
+				"No sun, but not bad!",
+				"Typical winter weather!",
+			},
+			ConditionPartlyCloudy: {
+				"A mix of sun and clouds!",
+				"Nice, but it may get colder!",
+				"Changeable weather!",
+				"Clouds come and go!",
+			},
+			ConditionSnow: {
+				"Snow is falling - Winter magic!",
+				"A white blanket on the ground!",
+				"Winter is here - Dress warmly!",
+				"The snowy landscape is spectacular!",
+			},
+			ConditionFog: {
+				"Thick fog - Drive slowly!",
+				"You can't see your hand in front of you!",
+			},
+			ConditionShowers: {
+				"A downpour is coming - Take cover!",
+				"Short but fierce!",
+			},
+			ConditionSnowShowers: {
+				"Snow showers - Watch the roads!",
+				"Snow in bursts!",
+			},
+			ConditionThunderstorm: {
+				"Thunderstorm - Stay indoors!",
+				"Lightning splits the sky!",
+			},
+			ConditionMainlyClear: {
+				"Just a few clouds - Enjoy!",
+				"An almost perfect sky!",
+			},
+			ConditionDrizzle: {
+				"Light drizzle - A jacket will do!",
+				"The drizzle won't stop!",
+			},
+			ConditionFreezingDrizzle: {
+				"Freezing drizzle - Pavements are slippery!",
+				"Careful, ice on the roads!",
+			},
+			ConditionFreezingRain: {
+				"Freezing rain - Stay home if you can!",
+				"Everything is turning into a rink!",
+			},
+			ConditionSnowGrains: {
+				"Snow grains in the air!",
+				"Fine snow is sifting down!",
+			},
+			ConditionThunderstormHail: {
+				"Hail and thunder - Park the car under cover!",
+				"Ice is falling from the sky!",
+			},
+		},
+		night: []string{
+			"A clear night - The stars are out!",
+			"The moon lights up the city!",
+		},
+		winds: map[LocalWind]string{
+			WindBura:     "Bora",
+			WindJugo:     "Jugo",
+			WindMaestral: "Maestral",
+		},
+		windMessages: map[LocalWind][]string{
+			WindBura: {
+				"The bora is blowing - Hold on to the railing!",
+				"The bora cuts to the bone!",
+			},
+			WindJugo: {
+				"Jugo is raising the sea - Headache weather!",
+				"Jugo blows, rain is coming!",
+			},
+			WindMaestral: {
+				"The maestral cools the afternoon!",
+				"Perfect for sailing!",
+			},
+		},
+		alerts: map[AlertKind]string{
+			AlertGale:         "Gale - Secure loose objects outdoors!",
+			AlertBura:         "Strong bora - Bridges and ferries may close!",
+			AlertJugo:         "Strong jugo - High waves on the open sea!",
+			AlertThunderstorm: "Thunderstorm - Avoid open spaces!",
+			AlertPressureDrop: "Pressure is falling fast - Rough weather likely, sailors take care!",
+		},
+		compass:  [16]string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"},
+		weekdays: [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+	},
+	LangGerman: {
+		conditions: map[Condition]string{
+			ConditionClear:            "Sonnig",
+			ConditionPartlyCloudy:     "Teilweise bewölkt",
+			ConditionCloudy:           "Bewölkt",
+			ConditionFog:              "Nebel",
+			ConditionRain:             "Regen",
+			ConditionSnow:             "Schnee",
+			ConditionShowers:          "Schauer",
+			ConditionSnowShowers:      "Schneeschauer",
+			ConditionThunderstorm:     "Gewitter",
+			ConditionMainlyClear:      "Überwiegend klar",
+			ConditionDrizzle:          "Nieselregen",
+			ConditionFreezingDrizzle:  "Gefrierender Nieselregen",
+			ConditionFreezingRain:     "Gefrierender Regen",
+			ConditionSnowGrains:       "Griesel",
+			ConditionThunderstormHail: "Gewitter mit Hagel",
+		},
+		messages: map[Condition][]string{
+			ConditionRain: {
+				"Es regnet - Regenschirm mitnehmen!",
+				"Nass da draußen - Bleib trocken!",
+				"Der Himmel öffnet sich - Bleib drinnen!",
+				"Der Regen ist da - Schmuddelwetter!",
+			},
+			ConditionClear: {
+				"Die Sonne scheint - Herrliches Wetter!",
+				"Sonnenschutz empfohlen!",
+				"Der schönste Tag des Jahres!",
+				"Ideal für die Berge!",
+			},
+			ConditionCloudy: {
+				"Wolken bedecken den Himmel!",
+				"Leicht grau - aber angenehm!",
+				"Keine Sonne, aber nicht schlecht!",
+				"Typisches Winterwetter!",
+			},
+			ConditionPartlyCloudy: {
+				"Eine Mischung aus Sonne und Wolken!",
+				"Schön, aber es kann kälter werden!",
+				"Wechselhaftes Wetter!",
+				"Wolken kommen und gehen!",
+			},
+			ConditionSnow: {
+				"Es schneit - Winterzauber!",
+				"Eine weiße Decke auf dem Boden!",
+				"Der Winter ist da - Warm anziehen!",
+				"Die Schneelandschaft ist spektakulär!",
+			},
+			ConditionFog: {
+				"Dichter Nebel - Langsam fahren!",
+				"Man sieht die Hand vor Augen nicht!",
+			},
+			ConditionShowers: {
+				"Ein Schauer kommt - Schutz suchen!",
+				"Kurz, aber heftig!",
+			},
+			ConditionSnowShowers: {
+				"Schneeschauer - Vorsicht auf den Straßen!",
+				"Schnee in Böen!",
+			},
+			ConditionThunderstorm: {
+				"Gewitter - Bleib drinnen!",
+				"Blitze zerreißen den Himmel!",
+			},
+			ConditionMainlyClear: {
+				"Nur ein paar Wolken - Genieße es!",
+				"Ein fast perfekter Himmel!",
+			},
+			ConditionDrizzle: {
+				"Leichter Niesel - Eine Jacke reicht!",
+				"Der Niesel hört nicht auf!",
+			},
+			ConditionFreezingDrizzle: {
+				"Gefrierender Niesel - Gehwege sind glatt!",
+				"Vorsicht, Glatteis!",
+			},
+			ConditionFreezingRain: {
+				"Eisregen - Bleib zu Hause, wenn du kannst!",
+				"Alles wird zur Eisbahn!",
+			},
+			ConditionSnowGrains: {
+				"Griesel in der Luft!",
+				"Feiner Schnee rieselt!",
+			},
+			ConditionThunderstormHail: {
+				"Hagel und Donner - Auto unterstellen!",
+				"Eis fällt vom Himmel!",
+			},
+		},
+		night: []string{
+			"Eine klare Nacht - Die Sterne sind da!",
+			"Der Mond erhellt die Stadt!",
+		},
+		winds: map[LocalWind]string{
+			WindBura:     "Bora",
+			WindJugo:     "Jugo",
+			WindMaestral: "Maestral",
+		},
+		windMessages: map[LocalWind][]string{
+			WindBura: {
+				"Die Bora weht - Gut festhalten!",
+				"Die Bora geht durch Mark und Bein!",
+			},
+			WindJugo: {
+				"Jugo wühlt das Meer auf - Kopfschmerzwetter!",
+				"Jugo weht, Regen kommt!",
+			},
+			WindMaestral: {
+				"Der Maestral kühlt den Nachmittag!",
+				"Ideal zum Segeln!",
+			},
+		},
+		alerts: map[AlertKind]string{
+			AlertGale:         "Sturm - Gegenstände im Freien sichern!",
+			AlertBura:         "Starke Bora - Brücken und Fähren können gesperrt werden!",
+			AlertJugo:         "Starker Jugo - Hohe Wellen auf offener See!",
+			AlertThunderstorm: "Gewitter - Offene Flächen meiden!",
+			AlertPressureDrop: "Luftdruck fällt rasch - Unwetter möglich, Vorsicht auf See!",
+		},
+		compass:  [16]string{"N", "NNO", "NO", "ONO", "O", "OSO", "SO", "SSO", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"},
+		weekdays: [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+	},
+}
+
+// catalog returns the language's catalog, falling back to the default language
+func (l Language) catalog() catalog {
+	if cat, ok := catalogs[l]; ok {
+		return cat
+	}
+	return catalogs[DefaultLanguage]
+}
+
+// ConditionName returns the display name of a condition
+func (l Language) ConditionName(c Condition) string {
+	if name, ok := l.catalog().conditions[c]; ok {
+		return name
+	}
+	return catalogs[DefaultLanguage].conditions[c]
+}
+
+// Message returns the n-th dramatic message for a condition, wrapping around the catalog
+func (l Language) Message(c Condition, n int, isDay bool) string {
+	messages := l.catalog().messages[c]
+	if !isDay && (c == ConditionClear || c == ConditionMainlyClear) {
+		messages = l.catalog().night
+	}
+	if len(messages) == 0 {
+		messages = l.catalog().messages[ConditionClear]
+	}
+	return messages[n%len(messages)]
+}
+
+// Weekday returns the localised name of a day
+func (l Language) Weekday(d time.Weekday) string {
+	return l.catalog().weekdays[d]
+}
+
+// weather fills in the localised text of current conditions: condition and wind
+// names, the dramatic message and alerts
+func (l Language) weather(d WeatherData) WeatherData {
+	cat := l.catalog()
+	d.Condition = l.ConditionName(d.ConditionID)
+	d.WindCompass = cat.compass[compassPoint(d.WindDirection)]
+	d.LocalWindName = cat.winds[d.LocalWind]
+	if messages := cat.windMessages[d.LocalWind]; len(messages) > 0 {
+		d.DramaticMessage = messages[d.message%len(messages)]
+	} else {
+		d.DramaticMessage = l.Message(d.ConditionID, d.message, d.IsDay)
+	}
+	if d.LocalForecast != nil {
+		forecast := *d.LocalForecast
+		forecast.Condition = l.ConditionName(forecast.ConditionID)
+		d.LocalForecast = &forecast
+	}
+	d.Alerts = weatherAlerts(d)
+	for i := range d.Alerts {
+		d.Alerts[i].Message = cat.alerts[d.Alerts[i].Kind]
+	}
+	return d
+}
+
+// languageFromAccept picks the preferred supported language from an Accept-Language header
+func languageFromAccept(header string) (Language, bool) {
+	type candidate struct {
+		lang Language
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		lang := Language(base)
+		if _, ok := catalogs[lang]; !ok {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{lang, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang, true
+}
+
+// requestLanguage reads ?lang=, falling back to Accept-Language and then Croatian.
+// It writes a 400 and returns false for an unsupported lang=.
+func requestLanguage(w http.ResponseWriter, r *http.Request) (Language, bool) {
+	if v := r.URL.Query().Get("lang"); v != "" {
+		lang := Language(strings.ToLower(v))
+		if _, ok := catalogs[lang]; !ok {
+			writeJSONError(w, http.StatusBadRequest, "lang must be hr, en or de")
+			return "", false
+		}
+		return lang, true
+	}
+	varyOn(w, "Accept-Language")
+	if lang, ok := languageFromAccept(r.Header.Get("Accept-Language")); ok {
+		return lang, true
+	}
+	return DefaultLanguage, true
+}
+
+// varyOn adds a request header to Vary once
+func varyOn(w http.ResponseWriter, header string) {
+	for _, v := range w.Header().Values("Vary") {
+		if strings.EqualFold(v, header) {
+			return
+		}
+	}
+	w.Header().Add("Vary", header)
+}
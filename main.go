@@ -2,81 +2,120 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"math/rand"
 	"net/http"
 	"os"
-	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
-// Configuration
-const (
+// Configuration defaults, overridden by flags, environment or config file (see config.go)
+var (
 	CacheRefreshInterval = 5 * time.Minute
 	APITimeout           = 10 * time.Second
-	MaxRequests          = 100 // Rate limiting: requests per minute
 )
 
-// RequestTracker for rate limiting
-type RequestTracker struct {
-	Count     int
-	LastReset time.Time
-	Mutex     sync.Mutex
-}
+const (
+	MaxRequests    = 100 // Rate limiting: requests per minute per client
+	CoordCacheTTL  = 15 * time.Minute
+	CoordCacheSize = 512 // Coordinate lookups kept in the LRU
+)
 
-var requestTracker = &RequestTracker{
-	LastReset: time.Now(),
-}
+const (
+	DefaultForecastDays = 5
+	MaxForecastDays     = 16 // longest forecast Open-Meteo serves
+)
 
-// CachedWeatherData stores weather data and when it was last updated
+// CachedWeatherData holds a city's current snapshot; readers never take a lock
 type CachedWeatherData struct {
-	Data      WeatherData
-	Timestamp time.Time
-	Mutex     sync.RWMutex
+	snapshot atomic.Pointer[weatherSnapshot]
 }
 
-// HistoricalData stores weather history for trends
-type HistoricalData struct {
-	Location     string
-	Temperatures []int
-	Times        []time.Time
-	Mutex        sync.RWMutex
-}
-
-// Global cache for weather data - updates only every 5 minutes
-var weatherCache = make(map[string]*CachedWeatherData)
-var cacheLock sync.RWMutex
-
-// Historical data for each city
-var historicalData = make(map[string]*HistoricalData)
-var historyLock sync.RWMutex
+// Global cache for weather data, swapped copy-on-write so readers never lock.
+// cacheLock serialises writers adding or removing cities, and registry-checked writes against eviction.
+var weatherCache atomic.Pointer[map[string]*CachedWeatherData]
+var cacheLock sync.Mutex
 
 // WeatherData represents current weather information
 type WeatherData struct {
-	Location        string
-	Temperature     int
-	Condition       string
-	Emoji           string
-	Description     string
-	DramaticMessage string
-	WindSpeed       int
-	Humidity        int
-	FeelsLike       int
-	UVIndex         float64
-	PrecipChance    int
+	Location         string
+	Temperature      int
+	ConditionID      Condition // language-neutral key, e.g. "partly_cloudy"
+	Condition        string    // display name in the response language
+	Intensity        Intensity // light, moderate or heavy; empty when it does not apply
+	IsDay            bool
+	Emoji            string
+	Description      string
+	DramaticMessage  string
+	WindSpeed        int
+	WindDirection    int    // degrees, where the wind comes from
+	WindCompass      string // 16-point compass label in the response language
+	WindGusts        int
+	Beaufort         int
+	LocalWind        LocalWind // bura, jugo or maestral on the coast; empty otherwise
+	LocalWindName    string
+	Humidity         int
+	FeelsLike        int
+	UVIndex          float64
+	PrecipChance     int
+	Pressure         float64           // hPa at sea level, 0 when unknown
+	SurfacePressure  float64           // hPa at station height, 0 when unknown
+	PressureTendency *PressureTendency // from recorded history, null until there is enough
+	LocalForecast    *LocalForecast    // offline Zambretti forecast, null without pressure history
+
+	// Extended observations, left out when the provider has no value
+	DewPoint      *float64 `json:",omitempty"` // °C
+	Visibility    *float64 `json:",omitempty"` // km
+	CloudCover    *int     `json:",omitempty"` // %
+	Precipitation *float64 `json:",omitempty"` // mm in the last hour
+	Rain          *float64 `json:",omitempty"` // mm in the last hour
+	Snowfall      *float64 `json:",omitempty"` // cm in the last hour
+	SnowDepth     *float64 `json:",omitempty"` // cm
+
+	Alerts []Alert
+
+	message  int             // which of the condition's dramatic messages to show
+	readings *metricReadings // unrounded, so unit conversion rounds only once
+}
+
+// metricReadings are the provider readings behind the whole-number fields of WeatherData
+type metricReadings struct {
+	Temperature float64
+	FeelsLike   float64
+	WindSpeed   float64
+	WindGusts   float64
 }
 
 // ForecastDay represents a day's forecast
 type ForecastDay struct {
-	Date      string
-	High      int
-	Low       int
-	Emoji     string
-	Condition string
+	Date        string // ISO date in the city's timezone
+	Day         string // weekday name in the response language
+	IsToday     bool
+	High        int
+	Low         int
+	Emoji       string
+	ConditionID Condition
+	Condition   string
+	Intensity   Intensity
+
+	// Daily totals and maxima, left out when the provider has no value
+	PrecipitationSum *float64 `json:",omitempty"` // mm
+	RainSum          *float64 `json:",omitempty"` // mm
+	SnowfallSum      *float64 `json:",omitempty"` // cm
+	WindSpeedMax     *float64 `json:",omitempty"` // km/h
+	WindGustsMax     *float64 `json:",omitempty"` // km/h
+
+	high, low float64 // unrounded °C, for unit conversion
 }
 
 // WeatherForecast contains current weather and forecast
@@ -84,6 +123,14 @@ type WeatherForecast struct {
 	Current  WeatherData
 	Forecast []ForecastDay
 	AsciiArt string
+	Source   string
+	Fallback bool
+	Stale    bool           // upstream refresh failed, data is older than the cache TTL
+	Trend    *WeatherTrends // from recorded history, null for coordinate lookups
+	Units    Units
+	Language Language
+	Timezone string // IANA name of the location's timezone
+	Updated  string // RFC 3339 in the location's timezone
 }
 
 // CityCoordinates stores latitude and longitude for a city
@@ -92,158 +139,65 @@ type CityCoordinates struct {
 	Latitude  float64
 	Longitude float64
 	Emoji     string
+	Timezone  string
+	Coastal   bool // Adriatic wind regimes apply
 }
 
 // OpenMeteo API response structures
 type OpenMeteoResponse struct {
 	Current struct {
-		Temperature float64 `json:"temperature_2m"`
-		Humidity    int     `json:"relative_humidity_2m"`
-		WindSpeed   float64 `json:"wind_speed_10m"`
-		WeatherCode int     `json:"weather_code"`
-		Time        string  `json:"time"`
+		Temperature              float64  `json:"temperature_2m"`
+		ApparentTemperature      *float64 `json:"apparent_temperature"`
+		Humidity                 int      `json:"relative_humidity_2m"`
+		WindSpeed                float64  `json:"wind_speed_10m"`
+		WindDirection            int      `json:"wind_direction_10m"`
+		WindGusts                *float64 `json:"wind_gusts_10m"`
+		UVIndex                  *float64 `json:"uv_index"`
+		PrecipitationProbability *int     `json:"precipitation_probability"`
+		PressureMSL              *float64 `json:"pressure_msl"`
+		SurfacePressure          *float64 `json:"surface_pressure"`
+		DewPoint                 *float64 `json:"dew_point_2m"`
+		Visibility               *float64 `json:"visibility"`
+		CloudCover               *int     `json:"cloud_cover"`
+		Precipitation            *float64 `json:"precipitation"`
+		Rain                     *float64 `json:"rain"`
+		Snowfall                 *float64 `json:"snowfall"`
+		SnowDepth                *float64 `json:"snow_depth"`
+		WeatherCode              int      `json:"weather_code"`
+		IsDay                    *int     `json:"is_day"`
+		Time                     string   `json:"time"`
 	} `json:"current"`
 	Daily struct {
 		Time           []string  `json:"time"`
 		WeatherCode    []int     `json:"weather_code"`
 		TemperatureMax []float64 `json:"temperature_2m_max"`
 		TemperatureMin []float64 `json:"temperature_2m_min"`
+		// Optional sums and maxima; a day without a value is null
+		PrecipitationSum []*float64 `json:"precipitation_sum"`
+		RainSum          []*float64 `json:"rain_sum"`
+		SnowfallSum      []*float64 `json:"snowfall_sum"`
+		WindSpeedMax     []*float64 `json:"wind_speed_10m_max"`
+		WindGustsMax     []*float64 `json:"wind_gusts_10m_max"`
 	} `json:"daily"`
+	Hourly struct {
+		Time                     []string  `json:"time"`
+		Temperature              []float64 `json:"temperature_2m"`
+		PrecipitationProbability []int     `json:"precipitation_probability"`
+		Precipitation            []float64 `json:"precipitation"`
+		WindSpeed                []float64 `json:"wind_speed_10m"`
+		WindDirection            []int     `json:"wind_direction_10m"`
+		WindGusts                []float64 `json:"wind_gusts_10m"`
+		WeatherCode              []int     `json:"weather_code"`
+		IsDay                    []int     `json:"is_day"`
+	} `json:"hourly"`
 }
 
-// City coordinates for Croatian cities
-var cityCoordinates = map[string]CityCoordinates{
-	"zagreb": {
-		Name:      "Zagreb 🏛️",
-		Latitude:  45.815,
-		Longitude: 15.9819,
-		Emoji:     "🏛️",
-	},
-	"split": {
-		Name:      "Split 🏖️",
-		Latitude:  43.5081,
-		Longitude: 16.4402,
-		Emoji:     "🏖️",
-	},
-	"dubrovnik": {
-		Name:      "Dubrovnik ⛱️",
-		Latitude:  42.6412,
-		Longitude: 18.1084,
-		Emoji:     "⛱️",
-	},
-	"rijeka": {
-		Name:      "Rijeka 🌊",
-		Latitude:  45.3271,
-		Longitude: 14.4205,
-		Emoji:     "🌊",
-	},
-	"zadar": {
-		Name:      "Zadar 🐚",
-		Latitude:  43.1312,
-		Longitude: 15.2313,
-		Emoji:     "🐚",
-	},
-	"osijek": {
-		Name:      "Osijek 🌾",
-		Latitude:  45.5544,
-		Longitude: 18.6955,
-		Emoji:     "🌾",
-	},
-}
+// City coordinates and mock data, loaded from the city registry (see cities.go)
+var cityCoordinates = make(map[string]CityCoordinates)
+var locations = make(map[string]WeatherData)
 
-// Mock data
-var locations = map[string]WeatherData{
-	"zagreb": {
-		Location:    "Zagreb 🏛️",
-		Temperature: 3,
-		Condition:   "Oblačno",
-		Emoji:       "☁️",
-		WindSpeed:   12,
-		Humidity:    75,
-		FeelsLike:   0,
-	},
-	"split": {
-		Location:    "Split 🏖️",
-		Temperature: 11,
-		Condition:   "Sunčano",
-		Emoji:       "☀️",
-		WindSpeed:   8,
-		Humidity:    65,
-		FeelsLike:   10,
-	},
-	"dubrovnik": {
-		Location:    "Dubrovnik ⛱️",
-		Temperature: 13,
-		Condition:   "Sunčano",
-		Emoji:       "☀️",
-		WindSpeed:   5,
-		Humidity:    60,
-		FeelsLike:   12,
-	},
-	"rijeka": {
-		Location:    "Rijeka 🌊",
-		Temperature: 5,
-		Condition:   "Kišno",
-		Emoji:       "🌧️",
-		WindSpeed:   18,
-		Humidity:    88,
-		FeelsLike:   2,
-	},
-	"zadar": {
-		Location:    "Zadar 🐚",
-		Temperature: 10,
-		Condition:   "Djelomično oblačno",
-		Emoji:       "⛅",
-		WindSpeed:   10,
-		Humidity:    70,
-		FeelsLike:   8,
-	},
-	"osijek": {
-		Location:    "Osijek 🌾",
-		Temperature: 7,
-		Condition:   "Oblačno",
-		Emoji:       "☁️",
-		WindSpeed:   10,
-		Humidity:    72,
-		FeelsLike:   5,
-	},
-}
-
-var dramaticMessages = map[string][]string{
-	"Kišno": {
-		"Kiša pada - Donesi kišobran!",
-		"Mokri ulazak - Čuva se od kiše!",
-		"Nebo se prazni - Ostani unutar!",
-		"Kiša je ovdje - Bodljikavo vrijeme!",
-	},
-	"Sunčano": {
-		"Sunce sjaji - Divno vrijeme!",
-		"Zaštita od sunca preporučena!",
-		"Najljepši dan godine!",
-		"Idealno za planinu!",
-	},
-	"Oblačno": {
-		"Oblaci pokrivaju nebo!",
-		"Blago sive boje - ali ugodno!",
-		"Nema sunca ali nije loše!",
-		"Tipično zimsko vrijeme!",
-	},
-	"Djelomično oblačno": {
-		"Mješavina sunca i oblaka!",
-		"Lijepo, ali može biti hladnije!",
-		"Promjenjivo vrijeme!",
-		"Oblaci se pojavljuju i nestaju!",
-	},
-	"Snježno": {
-		"Snijeg pada - Zimska čarolija!",
-		"Bijela pokrivka na zemlji!",
-		"Zimski podaci - Odjevite se toplo!",
-		"Snježni pejzaž je spektakularan!",
-	},
-}
-
-var asciiArts = map[string]string{
-	"Kišno": `
+var asciiArts = map[Condition]string{
+	ConditionRain: `
     ___
    (____)
    /    \
@@ -253,7 +207,7 @@ var asciiArts = map[string]string{
     /|  |\
    / |  | \
   `,
-	"Sunčano": `
+	ConditionClear: `
       \  |  /
        \ | /
         \|/
@@ -262,7 +216,7 @@ var asciiArts = map[string]string{
        / | \
       /  |  \
   `,
-	"Snježno": `
+	ConditionSnow: `
      *  *  *
     *  ❄️  *
      *  *  *
@@ -270,205 +224,203 @@ var asciiArts = map[string]string{
   *    *    *
     *  *  *
   `,
-	"Oblačno": `
+	ConditionCloudy: `
     (    )
      ( )
     _____
    |     |
   `,
-	"Djelomično oblačno": `
+	ConditionPartlyCloudy: `
       \  |  /
        \ | /
         \|/
     --- (*) ---
     (    )
      ( )
+  `,
+	ConditionFog: `
+    _ - _ - _ -
+     - _ - _ -
+    _ - _ - _ -
+     - _ - _ -
+  `,
+	ConditionThunderstorm: `
+    (    )
+   (______)
+     /  /
+    /_ /_
+     //
+    /'
   `,
 }
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-	// Initialize historical data for all cities
-	cities := []string{"zagreb", "split", "dubrovnik", "rijeka", "zadar", "osijek"}
-	for _, city := range cities {
-		historicalData[city] = &HistoricalData{
-			Location:     city,
-			Temperatures: make([]int, 0),
-			Times:        make([]time.Time, 0),
-		}
-	}
+// nightArts replace the sun with the moon between sunset and sunrise
+var nightArts = map[Condition]string{
+	ConditionClear: `
+        _..._      *
+      .'  .-'
+     /   /       *
+    |   |
+     \   \    *
+      '._ '-._
+         '-..-'   *
+  `,
+	ConditionPartlyCloudy: `
+        _..._
+      .'  .-'
+     /   /
+    |   |  (    )
+     '._'-( )
+  `,
 }
 
-// rateLimit checks if request is within limits
-func rateLimit(w http.ResponseWriter) bool {
-	requestTracker.Mutex.Lock()
-	defer requestTracker.Mutex.Unlock()
-
-	now := time.Now()
-	if now.Sub(requestTracker.LastReset) > time.Minute {
-		requestTracker.Count = 0
-		requestTracker.LastReset = now
-	}
-
-	if requestTracker.Count >= MaxRequests {
-		w.WriteHeader(http.StatusTooManyRequests)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Rate limit exceeded"})
-		return false
-	}
+// asciiArtFallbacks share the art of a similar condition
+var asciiArtFallbacks = map[Condition]Condition{
+	ConditionMainlyClear:      ConditionClear,
+	ConditionDrizzle:          ConditionRain,
+	ConditionFreezingDrizzle:  ConditionRain,
+	ConditionFreezingRain:     ConditionRain,
+	ConditionShowers:          ConditionRain,
+	ConditionSnowGrains:       ConditionSnow,
+	ConditionSnowShowers:      ConditionSnow,
+	ConditionThunderstormHail: ConditionThunderstorm,
+}
 
-	requestTracker.Count++
-	return true
+func init() {
+	rand.Seed(time.Now().UnixNano())
 }
 
-// setCommonHeaders sets HTTP headers for API responses
+// setCommonHeaders sets HTTP headers for API responses, keeping a Cache-Control set by requireScope
 func setCommonHeaders(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.Header().Set("Cache-Control", "public, max-age=300") // Cache for 5 minutes
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "public, max-age=300") // Cache for 5 minutes
+	}
 	w.Header().Set("X-Content-Type-Options", "nosniff")
 	w.Header().Set("X-Frame-Options", "DENY")
 }
 
-// recordHistory records temperature for trend analysis
-func recordHistory(city string, temp int) {
-	historyLock.Lock()
-	defer historyLock.Unlock()
-
-	if hist, ok := historicalData[city]; ok {
-		hist.Mutex.Lock()
-		defer hist.Mutex.Unlock()
-
-		hist.Temperatures = append(hist.Temperatures, temp)
-		hist.Times = append(hist.Times, time.Now())
-
-		// Keep only last 48 data points (4 hours with 5-min intervals)
-		if len(hist.Temperatures) > 48 {
-			hist.Temperatures = hist.Temperatures[1:]
-			hist.Times = hist.Times[1:]
-		}
-	}
+// fetchRealWeather fetches a city's report from the active provider
+func fetchRealWeather(cityKey string) (CityCoordinates, *WeatherReport, error) {
+	return fetchWeatherFrom(weatherProvider, cityKey)
 }
 
-// getTemperatureTrend returns the trend (up/down/stable)
-func getTemperatureTrend(city string) string {
-	historyLock.RLock()
-	defer historyLock.RUnlock()
-
-	if hist, ok := historicalData[city]; ok {
-		hist.Mutex.RLock()
-		defer hist.Mutex.RUnlock()
-
-		if len(hist.Temperatures) < 2 {
-			return "stable"
-		}
+// fetchWeatherFrom fetches a city's report from the given provider
+func fetchWeatherFrom(provider WeatherProvider, cityKey string) (CityCoordinates, *WeatherReport, error) {
+	coords, ok := lookupCity(cityKey)
+	if !ok {
+		return coords, nil, fmt.Errorf("city not found: %s", cityKey)
+	}
 
-		current := hist.Temperatures[len(hist.Temperatures)-1]
-		previous := hist.Temperatures[len(hist.Temperatures)-2]
+	log.Printf("Fetching weather data for %s at coordinates (%.4f, %.4f) from %s", cityKey, coords.Latitude, coords.Longitude, provider.Name())
 
-		if current > previous {
-			return "rising"
-		} else if current < previous {
-			return "falling"
-		}
-		return "stable"
+	report, err := provider.Fetch(cityQuery(coords))
+	if err != nil {
+		return coords, nil, err
 	}
-	return "stable"
+	return coords, report, nil
 }
 
-// WMO code to Croatian condition mapping
-// https://www.weatherapi.com/docs/weather_codes.asp
-func wmoCodeToCondition(code int) (string, string) {
-	switch {
-	case code == 0, code == 1:
-		return "Sunčano", "☀️"
-	case code == 2:
-		return "Djelomično oblačno", "⛅"
-	case code == 3:
-		return "Oblačno", "☁️"
-	case code == 45, code == 48:
-		return "Magla", "🌫️"
-	case code >= 51 && code <= 67:
-		return "Kišno", "🌧️"
-	case code >= 71 && code <= 77: // || code == 80 || code == 81:
-		return "Snježno", "❄️"
-	case code >= 80 && code <= 82:
-		return "Pljuskovi", "⛈️"
-	case code >= 85 && code <= 86:
-		return "Snježni pljuskovi", "🌨️"
-	case code >= 80 && code <= 82 || code >= 85 && code <= 86:
-		return "Oluja", "⛈️"
-	default:
-		return "Oblačno", "☁️"
+// cityQuery builds a provider query for a city
+func cityQuery(coords CityCoordinates) WeatherQuery {
+	return WeatherQuery{
+		Latitude:  coords.Latitude,
+		Longitude: coords.Longitude,
+		Timezone:  coords.Timezone,
 	}
 }
 
-// fetchRealWeather fetches real weather data from Open-Meteo API
-func fetchRealWeather(cityKey string) (*WeatherData, error) {
-	coords, ok := cityCoordinates[cityKey]
-	if !ok {
-		return nil, fmt.Errorf("city not found: %s", cityKey)
-	}
-
-	log.Printf("Fetching weather data for %s at coordinates (%.4f, %.4f)", cityKey, coords.Latitude, coords.Longitude)
-
-	// Open-Meteo API endpoint - free, no API key needed
-	url := fmt.Sprintf(
-		"https://api.open-meteo.com/v1/forecast?latitude=%.4f&longitude=%.4f&current=temperature_2m,relative_humidity_2m,weather_code,wind_speed_10m&daily=weather_code,temperature_2m_max,temperature_2m_min&timezone=Europe/Belgrade",
-		coords.Latitude,
-		coords.Longitude,
-	)
+// weatherDataFromReport converts a provider report into WeatherData
+func weatherDataFromReport(coords CityCoordinates, report *WeatherReport) *WeatherData {
+	current := report.Current
+	condition, intensity := lookupWMOCode(current.WeatherCode)
 
-	log.Printf("API URL: %s", url)
+	weatherData := &WeatherData{
+		Location:      coords.Name,
+		Temperature:   roundInt(current.Temperature),
+		ConditionID:   condition,
+		Intensity:     intensity,
+		IsDay:         current.IsDay,
+		Emoji:         condition.EmojiAt(current.IsDay),
+		WindSpeed:     roundInt(current.WindSpeed),
+		WindDirection: current.WindDirection,
+		Beaufort:      beaufort(current.WindSpeed),
+		Humidity:      current.Humidity,
+		Description:   "",
+	}
 
-	resp, err := http.Get(url)
+	// Prefer upstream values, compute or borrow from the hourly forecast otherwise
+	feels := feelsLike(current.Temperature, current.Humidity, current.WindSpeed)
+	if current.ApparentTemperature != nil {
+		feels = *current.ApparentTemperature
+	}
+	gusts := current.WindSpeed
+	if current.WindGusts != nil {
+		gusts = *current.WindGusts
+	} else if hour, ok := report.currentHour(); ok && hour.WindGusts > 0 {
+		gusts = hour.WindGusts
+	}
+	weatherData.FeelsLike = roundInt(feels)
+	weatherData.WindGusts = roundInt(gusts)
+	weatherData.readings = &metricReadings{
+		Temperature: current.Temperature,
+		FeelsLike:   feels,
+		WindSpeed:   current.WindSpeed,
+		WindGusts:   gusts,
+	}
+	loc := cityLocation(coords)
+	local, err := time.ParseInLocation("2006-01-02T15:04", current.Time, loc)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch weather: %w", err)
+		local = time.Now().In(loc)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %d - %s", resp.StatusCode, string(body))
+	weatherData.LocalWind = classifyLocalWind(coords.Coastal, current.WindDirection, current.WindSpeed, gusts, local)
+	if current.UVIndex != nil {
+		weatherData.UVIndex = *current.UVIndex
 	}
-
-	var omResponse OpenMeteoResponse
-	if err := json.NewDecoder(resp.Body).Decode(&omResponse); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if current.PressureMSL != nil {
+		weatherData.Pressure = round1(*current.PressureMSL)
+	}
+	if current.SurfacePressure != nil {
+		weatherData.SurfacePressure = round1(*current.SurfacePressure)
+	}
+	weatherData.DewPoint = current.DewPoint
+	if weatherData.DewPoint == nil {
+		dp := round1(dewPoint(current.Temperature, current.Humidity))
+		weatherData.DewPoint = &dp
+	}
+	if current.Visibility != nil {
+		km := round1(*current.Visibility / 1000)
+		weatherData.Visibility = &km
+	}
+	if current.SnowDepth != nil {
+		cm := round1(*current.SnowDepth * 100)
+		weatherData.SnowDepth = &cm
+	}
+	weatherData.CloudCover = current.CloudCover
+	weatherData.Precipitation = current.Precipitation
+	weatherData.Rain = current.Rain
+	weatherData.Snowfall = current.Snowfall
+	if current.PrecipitationProbability != nil {
+		weatherData.PrecipChance = *current.PrecipitationProbability
+	} else if hour, ok := report.currentHour(); ok {
+		weatherData.PrecipChance = hour.PrecipitationProbability
 	}
 
-	log.Printf("API response for %s: %+v", cityKey, omResponse)
-
-	condition, emoji := wmoCodeToCondition(omResponse.Current.WeatherCode)
+	weatherData.message = rand.Int() // Language.Message wraps it to the catalog size
+	weatherData.Description = getAsciiArt(condition, current.IsDay)
 
-	weatherData := &WeatherData{
-		Location:        coords.Name,
-		Temperature:     int(omResponse.Current.Temperature),
-		Condition:       condition,
-		Emoji:           emoji,
-		WindSpeed:       int(omResponse.Current.WindSpeed),
-		Humidity:        omResponse.Current.Humidity,
-		FeelsLike:       int(omResponse.Current.Temperature) - 2, // Rough estimate
-		DramaticMessage: "",
-		Description:     "",
-	}
-
-	weatherData.DramaticMessage = getDramaticMessage(weatherData.Condition)
-	weatherData.Description = getAsciiArt(weatherData.Condition)
-	log.Printf("Processed weather data for %s: %+v", cityKey, weatherData)
-
-	return weatherData, nil
+	localized := DefaultLanguage.weather(*weatherData)
+	return &localized
 }
 
-// getDramaticMessage returns a random dramatic weather message
-func getDramaticMessage(condition string) string {
-	messages, ok := dramaticMessages[condition]
-	if !ok {
-		messages = dramaticMessages["Sunčano"]
+// getAsciiArt returns ASCII art for the weather condition, with the moon at night
+func getAsciiArt(condition Condition, isDay bool) string {
+	if similar, ok := asciiArtFallbacks[condition]; ok {
+		condition = similar
+	}
+	if art, ok := nightArts[condition]; ok && !isDay {
+		return art
 	}
-	return messages[rand.Intn(len(messages))]
-}
-
-// getAsciiArt returns ASCII art for the weather condition
-func getAsciiArt(condition string) string {
 	art, ok := asciiArts[condition]
 	if !ok {
 		return "   (...weather brewing...)"
@@ -476,69 +428,55 @@ func getAsciiArt(condition string) string {
 	return art
 }
 
-// getDayInCroatian converts English day name to Croatian
-func getDayInCroatian(englishDay string) string {
-	dayMap := map[string]string{
-		"Monday":    "Ponedjeljak",
-		"Tuesday":   "Utorak",
-		"Wednesday": "Srijeda",
-		"Thursday":  "Četvrtak",
-		"Friday":    "Petak",
-		"Saturday":  "Subota",
-		"Sunday":    "Nedjelja",
-	}
-	if hr, ok := dayMap[englishDay]; ok {
-		return hr
-	}
-	return englishDay
-}
-
-// generateForecast creates a 5-day forecast
-func generateForecast() []ForecastDay {
-	forecast := make([]ForecastDay, 5)
-	for i := 0; i < 5; i++ {
-		englishDay := time.Now().AddDate(0, 0, i+1).Format("Monday")
-
-		// Generate temperature-appropriate conditions
-		high := rand.Intn(15) + 5  // 3-18°C
-		low := rand.Intn(4) + (-3) // -3-5°C
-
-		// Select condition based on temperature
-		var condition, emoji string
-		if high < 4 || low < -2 {
-			// Very cold - snow is appropriate
-			condition = "Snježno"
-			emoji = "❄️"
-		} else if high < 5 {
-			// Cold but above freezing - rain or cloudy
-			rainOrCloud := rand.Intn(2)
-			if rainOrCloud == 0 {
-				condition = "Kišno"
-				emoji = "🌧️"
-			} else {
-				condition = "Oblačno"
-				emoji = "☁️"
-			}
-		} else {
-			// Mild temperatures - varied conditions
-			conditions := []string{"Sunčano", "Oblačno", "Kišno", "Djelomično oblačno"}
-			emojis := []string{"☀️", "☁️", "🌧️", "⛅"}
-			idx := rand.Intn(len(conditions))
-			condition = conditions[idx]
-			emoji = emojis[idx]
+// forecastFromDaily converts up to days of provider forecast to ForecastDay format.
+// Provider dates are local to loc, and the first one is normally today.
+func forecastFromDaily(daily []DailyConditions, days int, lang Language, loc *time.Location) []ForecastDay {
+	forecast := make([]ForecastDay, 0)
+	today := time.Now().In(loc).Format("2006-01-02")
+	for i := 0; i < len(daily) && i < days; i++ {
+		condition, intensity := lookupWMOCode(daily[i].WeatherCode)
+		day := ForecastDay{
+			Date:        daily[i].Date,
+			IsToday:     daily[i].Date == today,
+			High:        roundInt(daily[i].TemperatureMax),
+			Low:         roundInt(daily[i].TemperatureMin),
+			ConditionID: condition,
+			Condition:   lang.ConditionName(condition),
+			Intensity:   intensity,
+			Emoji:       condition.Emoji(),
+
+			PrecipitationSum: daily[i].PrecipitationSum,
+			RainSum:          daily[i].RainSum,
+			SnowfallSum:      daily[i].SnowfallSum,
+			WindSpeedMax:     daily[i].WindSpeedMax,
+			WindGustsMax:     daily[i].WindGustsMax,
+
+			high: daily[i].TemperatureMax,
+			low:  daily[i].TemperatureMin,
 		}
-
-		forecast[i] = ForecastDay{
-			Date:      getDayInCroatian(englishDay),
-			High:      high,
-			Low:       low,
-			Condition: condition,
-			Emoji:     emoji,
+		if date, err := time.ParseInLocation("2006-01-02", daily[i].Date, loc); err == nil {
+			day.Day = lang.Weekday(date.Weekday())
 		}
+		forecast = append(forecast, day)
 	}
 	return forecast
 }
 
+// requestForecastDays reads ?days=, defaulting to DefaultForecastDays. It writes a 400
+// and returns false outside 1..MaxForecastDays.
+func requestForecastDays(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("days")
+	if v == "" {
+		return DefaultForecastDays, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > MaxForecastDays {
+		writeJSONError(w, http.StatusBadRequest, "days must be between 1 and "+strconv.Itoa(MaxForecastDays))
+		return 0, false
+	}
+	return n, true
+}
+
 // Handler for root path - HTML dashboard
 func weatherDashboardHandler(w http.ResponseWriter, r *http.Request) {
 	// Serve the static HTML dashboard (templates/index.html)
@@ -549,145 +487,98 @@ func weatherDashboardHandler(w http.ResponseWriter, r *http.Request) {
 
 // Handler for weather API endpoint
 func weatherAPIHandler(w http.ResponseWriter, r *http.Request) {
-	// Rate limiting
-	if !rateLimit(w) {
+	location := strings.ToLower(strings.TrimPrefix(r.URL.Path, "/api/weather/"))
+	if location == "" && r.URL.Query().Has("lat") {
+		coordinateWeatherHandler(w, r)
 		return
 	}
 
-	location := strings.ToLower(strings.TrimPrefix(r.URL.Path, "/api/weather/"))
-	log.Printf("Weather API request for location: %s", location)
-
-	// Get cached weather data
-	cacheLock.RLock()
-	cached, ok := weatherCache[location]
-	cacheLock.RUnlock()
+	// Rate limiting
+	if !rateLimit(w, r) {
+		return
+	}
 
+	units, ok := requestUnits(w, r)
 	if !ok {
-		log.Printf("Location not found in cache: %s", location)
-		w.WriteHeader(http.StatusNotFound)
-		setCommonHeaders(w)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Location not found"})
 		return
 	}
-
-	// Check if cache needs refresh (every 5 minutes)
-	cached.Mutex.Lock()
-	defer cached.Mutex.Unlock()
-
-	if time.Since(cached.Timestamp) > CacheRefreshInterval {
-		log.Printf("Refreshing weather data for %s", location)
-		// Refresh with real weather data from API
-		weather, err := fetchRealWeather(location)
-		if err != nil {
-			log.Printf("API refresh failed for %s: %v. Using cached data.\n", location, err)
-		} else {
-			// Successfully fetched real data
-			log.Printf("Successfully refreshed weather data for %s: %d°C, %s", location, weather.Temperature, weather.Condition)
-			cached.Data = *weather
-			cached.Data = *weather
-			cached.Timestamp = time.Now()
-			recordHistory(location, weather.Temperature)
-		}
+	lang, ok := requestLanguage(w, r)
+	if !ok {
+		return
+	}
+	days, ok := requestForecastDays(w, r)
+	if !ok {
+		return
 	}
 
-	// Add trend data
-	cached.Data.UVIndex = float64(rand.Intn(12))
-	cached.Data.PrecipChance = rand.Intn(100)
-
-	// Log the data being sent to the frontend
-	log.Printf("Sending weather data for %s: %+v", location, cached.Data)
-
-	forecast := WeatherForecast{
-		Current:  cached.Data,
-		Forecast: generateForecast(),
-		AsciiArt: cached.Data.Description,
+	snap, ok := getCachedWeather(location)
+	if !ok {
+		log.Printf("Location not found in cache: %s", location)
+		writeJSONError(w, http.StatusNotFound, "Location not found")
+		return
 	}
 
 	setCommonHeaders(w)
-	json.NewEncoder(w).Encode(forecast)
+
+	// Entries older than two refresh intervals mean the scheduler is failing
+	stale := snap.isStale(2 * CacheRefreshInterval)
+	if !stale && units == metricUnits && lang == DefaultLanguage && days == DefaultForecastDays {
+		w.Write(snap.weatherJSON)
+		return
+	}
+	json.NewEncoder(w).Encode(units.forecast(snap.weatherBody(stale, lang, days)))
 }
 
 // Handler for forecast endpoint
 func forecastAPIHandler(w http.ResponseWriter, r *http.Request) {
 	// Rate limiting
-	if !rateLimit(w) {
+	if !rateLimit(w, r) {
 		return
 	}
 
 	location := strings.ToLower(strings.TrimPrefix(r.URL.Path, "/api/forecast/"))
-
-	// Get cached weather data to ensure location exists
-	cacheLock.RLock()
-	cached, ok := weatherCache[location]
-	cacheLock.RUnlock()
-
+	units, ok := requestUnits(w, r)
 	if !ok {
-		w.WriteHeader(http.StatusNotFound)
-		setCommonHeaders(w)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Location not found"})
 		return
 	}
-
-	// Fetch real forecast data from Open-Meteo API
-	coords, _ := cityCoordinates[location]
-	url := fmt.Sprintf(
-		"https://api.open-meteo.com/v1/forecast?latitude=%.4f&longitude=%.4f&daily=weather_code,temperature_2m_max,temperature_2m_min&timezone=Europe/Belgrade",
-		coords.Latitude,
-		coords.Longitude,
-	)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		// Fallback to mock forecast
-		setCommonHeaders(w)
-		json.NewEncoder(w).Encode(WeatherForecast{
-			Current:  cached.Data,
-			Forecast: generateForecast(),
-		})
+	lang, ok := requestLanguage(w, r)
+	if !ok {
+		return
+	}
+	days, ok := requestForecastDays(w, r)
+	if !ok {
 		return
 	}
-	defer resp.Body.Close()
 
-	var omResponse OpenMeteoResponse
-	if err := json.NewDecoder(resp.Body).Decode(&omResponse); err != nil {
-		// Fallback to mock forecast
-		setCommonHeaders(w)
-		json.NewEncoder(w).Encode(WeatherForecast{
-			Current:  cached.Data,
-			Forecast: generateForecast(),
-		})
+	snap, ok := getCachedWeather(location)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Location not found")
+		return
+	}
+	if snap.isStale(ForecastMaxStale) {
+		writeJSONError(w, http.StatusServiceUnavailable, "Forecast unavailable")
 		return
 	}
 
-	// Convert real forecast data to ForecastDay format
-	forecast := make([]ForecastDay, 0)
-	for i := 0; i < len(omResponse.Daily.Time) && i < 5; i++ {
-		condition, emoji := wmoCodeToCondition(omResponse.Daily.WeatherCode[i])
-		englishDay := time.Now().AddDate(0, 0, i+1).Format("Monday")
-		forecast = append(forecast, ForecastDay{
-			Date:      getDayInCroatian(englishDay),
-			High:      int(omResponse.Daily.TemperatureMax[i]),
-			Low:       int(omResponse.Daily.TemperatureMin[i]),
-			Condition: condition,
-			Emoji:     emoji,
-		})
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(WeatherForecast{
-		Current:  cached.Data,
-		Forecast: forecast,
-	})
+	setCommonHeaders(w)
+	stale := snap.isStale(ForecastCacheTTL)
+	if !stale && units == metricUnits && lang == DefaultLanguage && days == DefaultForecastDays {
+		w.Write(snap.forecastJSON)
+		return
+	}
+	json.NewEncoder(w).Encode(units.forecast(snap.forecastBody(stale, lang, days)))
 }
 
 // Handler for ASCII art display
 func asciiHandler(w http.ResponseWriter, r *http.Request) {
-	condition := strings.ToLower(strings.TrimPrefix(r.URL.Path, "/ascii/"))
-	if condition == "" {
-		condition = "Sunčano"
+	// Accepts a condition key or its name in any language, e.g. /ascii/rain or /ascii/Kišno?night=1
+	condition, ok := parseCondition(strings.TrimPrefix(r.URL.Path, "/ascii/"))
+	if !ok {
+		condition = ConditionClear
 	}
+	night, _ := strconv.ParseBool(r.URL.Query().Get("night"))
 
-	art := getAsciiArt(condition)
+	art := getAsciiArt(condition, !night)
 
 	htmlTemplate := fmt.Sprintf(`
 <!DOCTYPE html>
@@ -728,77 +619,129 @@ func asciiHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
-	// Initialize cache with real weather data from Open-Meteo API
-	fmt.Println("📡 Fetching real weather data from Open-Meteo API...")
-	cities := []string{"zagreb", "split", "dubrovnik", "rijeka", "zadar", "osijek"}
-	for _, city := range cities {
-		log.Printf("Initializing weather data for %s", city)
-
-		// Try to fetch real weather data
-		weather, err := fetchRealWeather(city)
-		if err != nil {
-			// Fallback to mock data if API fails
-			log.Printf("⚠️ Failed to fetch real weather for %s: %v. Using fallback data.\n", city, err)
-			mockWeather, ok := locations[city]
-			if !ok {
-				log.Printf("⚠️ No mock data available for %s. Skipping.\n", city)
-				continue
-			}
-			mockWeather.DramaticMessage = getDramaticMessage(mockWeather.Condition)
-			mockWeather.Description = getAsciiArt(mockWeather.Condition)
-			weather = &mockWeather
-		} else {
-			fmt.Printf("✓ Loaded real weather for %s: %.0f°C, %s\n", city, float64(weather.Temperature), weather.Condition)
-		}
+	// Flags override WEATHER_* environment variables, which override the config file
+	cfg, args, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal("Config error:", err)
+	}
+	if cfg.PrintConfig {
+		cfg.print(os.Stdout)
+		return
+	}
+	cfg.apply()
 
-		weatherCache[city] = &CachedWeatherData{
-			Data:      *weather,
-			Timestamp: time.Now(),
+	// "weather-server keys ..." manages API keys instead of serving
+	if len(args) > 0 && args[0] == "keys" {
+		if err := runKeysCommand(cfg.KeysFile, args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, "keys:", err)
+			os.Exit(1)
 		}
-		log.Printf("✓ Weather data cached for %s\n", city)
+		return
+	}
+	if len(args) > 0 {
+		log.Fatalf("Unknown command %q", args[0])
+	}
+	if err := apiKeys.refresh(); err != nil {
+		log.Fatal("API key store error:", err)
 	}
-	fmt.Println("✓ Weather cache initialized!\n")
 
-	http.HandleFunc("/", weatherDashboardHandler)
-	http.HandleFunc("/api/weather/", weatherAPIHandler)
-	http.HandleFunc("/api/forecast/", forecastAPIHandler)
-	http.HandleFunc("/ascii/", asciiHandler)
-	http.HandleFunc("/shutdown", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Println("Shutting down server...")
-		os.Exit(0)
-	})
+	// SIGINT/SIGTERM and POST /shutdown stop the server gracefully
+	ctx, stop := signal.NotifyContext(serverCtx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Load the city registry; SIGHUP reloads it without a restart
+	cities, err := loadCityRegistry(cityRegistryPath)
+	if err != nil {
+		log.Fatal("City registry error:", err)
+	}
+	applyCityRegistry(cities)
+	go watchCityRegistry()
+
+	// Select the weather source (openmeteo, mock or replay)
+	provider, err := newWeatherProvider(cfg.Provider, cfg.APIURL, cfg.ReplayDir)
+	if err != nil {
+		log.Fatal("Provider error:", err)
+	}
+	if om, ok := provider.(*OpenMeteoProvider); ok {
+		om.RecordDir = cfg.RecordDir
+	}
+	weatherProvider = provider
+
+	// Keep history on disk unless history-store is memory
+	store, err := newHistoryStore(cfg.HistoryStore, cfg.DataDir)
+	if err != nil {
+		log.Fatal("History store error:", err)
+	}
+	historyStore = store
+	go runHistoryCompaction(ctx)
+
+	// Start from the cache saved at the last shutdown, then fetch fresh data
+	if err := loadCacheState(filepath.Join(cfg.DataDir, "cache.json")); err != nil {
+		log.Printf("⚠️ Ignoring saved cache state: %v", err)
+	}
+
+	// Initialize cache with real weather data from the provider
+	fmt.Printf("📡 Fetching weather data from %s...\n", weatherProvider.Name())
+	for _, city := range cityKeys() {
+		warmCity(city)
+	}
+	fmt.Println("✓ Weather cache initialized!")
+	fmt.Println()
+
+	// Keep the cache fresh in the background
+	go newRefreshScheduler(CacheRefreshInterval).Run(ctx)
+	go runRateLimitEviction(ctx)
+
+	// Every route declares the scope a caller's API key needs
+	handle := func(pattern string, scope Scope, handler http.HandlerFunc) {
+		http.HandleFunc(pattern, requireScope(scope, handler))
+	}
+	handle("/", ScopePublic, weatherDashboardHandler)
+	handle("/api/weather", ScopeRead, coordinateWeatherHandler)
+	handle("/api/weather/", ScopeRead, weatherAPIHandler)
+	handle("/api/forecast/", ScopeRead, forecastAPIHandler)
+	handle("/api/hourly/", ScopeRead, hourlyAPIHandler)
+	handle("/api/history/", ScopeRead, historyAPIHandler)
+	handle("/ascii/", ScopePublic, asciiHandler)
+	handle("/api/admin/cities", ScopeAdmin, adminCitiesHandler)
+	handle("/api/admin/cities/", ScopeAdmin, adminCitiesHandler)
+	handle("/api/admin/usage", ScopeAdmin, adminUsageHandler)
+	handle("/shutdown", ScopeShutdown, shutdownHandler)
 
 	// Serve static assets (CSS, JS)
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	handle("/static/", ScopePublic, http.StripPrefix("/static/", http.FileServer(http.Dir("static"))).ServeHTTP)
 
 	fmt.Println(`
 ╔════════════════════════════════════════════════════════════════╗
 ║         🌤️  VREMENSKA PROGNOZA SERVER IS STARTING 🌤️        ║
 ╚════════════════════════════════════════════════════════════════╝
 
-🌍 Available Locations:
-  • /api/weather/zagreb
-  • /api/weather/split
-  • /api/weather/dubrovnik
-  • /api/weather/rijeka
-  • /api/weather/zadar
-  • /api/weather/osijek
-
+🌍 Available Locations:`)
+	for _, city := range cityKeys() {
+		fmt.Printf("  • /api/weather/%s\n", city)
+	}
+	fmt.Println(`
 📊 API Endpoints:
   GET / ............................ Interactive Dashboard
   GET /api/weather/<location> ....... JSON Weather Data
-  GET /api/forecast/<location> ...... 5-Day Forecast
+  GET /api/weather?lat=..&lon=.. .... Weather for any coordinate
+  GET /api/forecast/<location> ...... Forecast (?days=1-16)
+  GET /api/hourly/<location>?hours=N  Hourly Forecast
+  GET /api/history/<location> ....... Recorded History (JSON or CSV)
   GET /ascii/<condition> ............ ASCII Weather Art
+  POST /shutdown .................... Graceful Shutdown (shutdown key)`)
+	url := dashboardURL(cfg.Listen)
+	fmt.Printf("\n🚀 Starting server on %s\nPress Ctrl+C to stop...\n\n", url)
 
-🚀 Starting server on http://localhost:8081
-Press Ctrl+C to stop...
-	`)
-
-	// Open browser automatically
-	exec.Command("explorer.exe", "http://localhost:8081").Start()
+	// Open browser automatically, unless running headless
+	if !cfg.NoBrowser {
+		openBrowser(url)
+	}
 
-	err := http.ListenAndServe(":8081", nil)
-	if err != nil {
+	if err := serveUntilShutdown(ctx, newHTTPServer(cfg.Listen), cfg.DataDir); err != nil {
 		log.Fatal("Server error:", err)
 	}
 }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	PressureTendencyWindow = 3 * time.Hour  // barometric tendency is reported per 3 hours
+	PressureMinSpan        = time.Hour      // shorter spans are too noisy to extrapolate
+	PressureSteadyChange   = 1.6            // hPa per 3 hours, below this the barometer is steady
+	PressureRapidChange    = 6.0            // hPa per 3 hours, typical ahead of a storm
+	LocalForecastMaxAge    = 12 * time.Hour // older pressure readings are not used for the local forecast
+	historyPressureLookup  = LocalForecastMaxAge + PressureTendencyWindow
+)
+
+// PressureTendency is the change of sea-level pressure over the last 3 hours
+type PressureTendency struct {
+	Change    float64 // hPa per 3 hours
+	Direction string  // rising, falling or steady
+	Rapid     bool
+}
+
+// LocalForecast is the Zambretti forecast for the next 12-24 hours, made offline from pressure alone
+type LocalForecast struct {
+	Zambretti   int // outcome 1-32 of the Zambretti table
+	ConditionID Condition
+	Condition   string // in the response language
+}
+
+// zambrettiConditions maps each Zambretti outcome to the closest condition.
+// 1-9 follow a falling barometer, 10-19 a steady one and 20-32 a rising one.
+var zambrettiConditions = [33]Condition{
+	1:  ConditionClear,        // settled fine
+	2:  ConditionClear,        // fine weather
+	3:  ConditionPartlyCloudy, // fine, becoming less settled
+	4:  ConditionPartlyCloudy, // fairly fine, showery later
+	5:  ConditionShowers,      // showery, becoming more unsettled
+	6:  ConditionCloudy,       // unsettled, rain later
+	7:  ConditionRain,         // rain at times, worse later
+	8:  ConditionRain,         // rain at times, becoming very unsettled
+	9:  ConditionRain,         // very unsettled, rain
+	10: ConditionClear,        // settled fine
+	11: ConditionClear,        // fine weather
+	12: ConditionMainlyClear,  // fine, possibly showers
+	13: ConditionPartlyCloudy, // fairly fine, showers likely
+	14: ConditionShowers,      // showery, bright intervals
+	15: ConditionCloudy,       // changeable, some rain
+	16: ConditionRain,         // unsettled, rain at times
+	17: ConditionRain,         // rain at frequent intervals
+	18: ConditionRain,         // very unsettled, rain
+	19: ConditionThunderstorm, // stormy, much rain
+	20: ConditionClear,        // settled fine
+	21: ConditionClear,        // fine weather
+	22: ConditionMainlyClear,  // becoming fine
+	23: ConditionMainlyClear,  // fairly fine, improving
+	24: ConditionPartlyCloudy, // fairly fine, possibly showers early
+	25: ConditionShowers,      // showery early, improving
+	26: ConditionPartlyCloudy, // changeable, mending
+	27: ConditionCloudy,       // rather unsettled, clearing later
+	28: ConditionCloudy,       // unsettled, probably improving
+	29: ConditionShowers,      // unsettled, short fine intervals
+	30: ConditionRain,         // very unsettled, finer at times
+	31: ConditionRain,         // stormy, possibly improving
+	32: ConditionThunderstorm, // stormy, much rain
+}
+
+// zambretti returns the Zambretti outcome for a sea-level pressure and its tendency
+func zambretti(pressure float64, direction string) int {
+	clamp := func(z float64, lo, hi int) int {
+		return min(max(int(math.Round(z)), lo), hi)
+	}
+	switch direction {
+	case "falling":
+		return clamp(127-0.12*pressure, 1, 9)
+	case "rising":
+		return clamp(185-0.16*pressure, 20, 32)
+	default:
+		return clamp(144-0.13*pressure, 10, 19)
+	}
+}
+
+// classifyPressureChange turns a 3-hour change into a tendency
+func classifyPressureChange(change float64) *PressureTendency {
+	t := &PressureTendency{Change: math.Round(change*10) / 10, Direction: "steady"}
+	if change >= PressureSteadyChange {
+		t.Direction = "rising"
+	} else if change <= -PressureSteadyChange {
+		t.Direction = "falling"
+	}
+	t.Rapid = math.Abs(change) >= PressureRapidChange
+	return t
+}
+
+// pressureOutlook computes the pressure tendency and local forecast of a city from its
+// recorded history, ending at the newest pressure reading no older than LocalForecastMaxAge.
+// Either result is nil when there is not enough history.
+func pressureOutlook(city string, now time.Time) (*PressureTendency, *LocalForecast) {
+	obs, err := historyStore.Observations(city, now.Add(-historyPressureLookup), now.Add(time.Second))
+	if err != nil {
+		return nil, nil
+	}
+	var readings []Observation
+	for _, o := range obs {
+		if _, ok := o.Values["pressure"]; ok {
+			readings = append(readings, o)
+		}
+	}
+	if len(readings) == 0 {
+		return nil, nil
+	}
+	last := readings[len(readings)-1]
+	if now.Sub(last.Time) > LocalForecastMaxAge {
+		return nil, nil
+	}
+
+	var first *Observation
+	for i := range readings {
+		if !readings[i].Time.Before(last.Time.Add(-PressureTendencyWindow)) {
+			first = &readings[i]
+			break
+		}
+	}
+	span := last.Time.Sub(first.Time)
+	if span < PressureMinSpan {
+		return nil, nil
+	}
+	change := (last.Values["pressure"] - first.Values["pressure"]) * float64(PressureTendencyWindow) / float64(span)
+	tendency := classifyPressureChange(change)
+
+	z := zambretti(last.Values["pressure"], tendency.Direction)
+	return tendency, &LocalForecast{Zambretti: z, ConditionID: zambrettiConditions[z]}
+}
+
+// applyLocalForecast replaces today and tomorrow of a fallback forecast with the
+// Zambretti outlook, which follows the real pressure history instead of mock data
+func applyLocalForecast(daily []DailyConditions, forecast *LocalForecast) {
+	code := conditionToWMOCode(forecast.ConditionID)
+	for i := 0; i < len(daily) && i < 2; i++ {
+		daily[i].WeatherCode = code
+	}
+}
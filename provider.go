@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// DefaultTimezone is used for upstream queries when nothing else is known
+var DefaultTimezone = "Europe/Belgrade"
+
+// WeatherQuery describes a request to a WeatherProvider
+type WeatherQuery struct {
+	Latitude  float64
+	Longitude float64
+	Timezone  string
+}
+
+// CurrentConditions holds provider-neutral current conditions
+type CurrentConditions struct {
+	Time                     string
+	Temperature              float64
+	ApparentTemperature      *float64 // nil when the provider has no value
+	Humidity                 int
+	WindSpeed                float64
+	WindDirection            int      // degrees, where the wind comes from
+	WindGusts                *float64 // nil when the provider has no value
+	UVIndex                  *float64
+	PrecipitationProbability *int
+	PressureMSL              *float64 // hPa, reduced to sea level
+	SurfacePressure          *float64 // hPa, at station height
+	DewPoint                 *float64 // °C
+	Visibility               *float64 // m
+	CloudCover               *int     // %
+	Precipitation            *float64 // mm, preceding hour
+	Rain                     *float64 // mm, preceding hour
+	Snowfall                 *float64 // cm, preceding hour
+	SnowDepth                *float64 // m
+	WeatherCode              int
+	IsDay                    bool
+}
+
+// DailyConditions holds one day of a provider forecast
+type DailyConditions struct {
+	Date           string
+	WeatherCode    int
+	TemperatureMax float64
+	TemperatureMin float64
+
+	PrecipitationSum *float64 // mm
+	RainSum          *float64 // mm
+	SnowfallSum      *float64 // cm
+	WindSpeedMax     *float64 // km/h
+	WindGustsMax     *float64 // km/h
+}
+
+// HourlyConditions holds one hour of a provider forecast
+type HourlyConditions struct {
+	Time                     time.Time
+	Temperature              float64
+	PrecipitationProbability int
+	Precipitation            float64
+	WindSpeed                float64
+	WindDirection            int
+	WindGusts                float64
+	WeatherCode              int
+	IsDay                    bool
+}
+
+// WeatherReport is what every WeatherProvider returns for a coordinate
+type WeatherReport struct {
+	Source  string
+	Current CurrentConditions
+	Daily   []DailyConditions
+	Hourly  []HourlyConditions
+}
+
+// currentHour returns the hourly entry covering the current time
+func (r *WeatherReport) currentHour() (HourlyConditions, bool) {
+	now := time.Now()
+	for _, h := range r.Hourly {
+		if !now.Before(h.Time) && now.Before(h.Time.Add(time.Hour)) {
+			return h, true
+		}
+	}
+	return HourlyConditions{}, false
+}
+
+// WeatherProvider fetches current conditions and forecasts for a coordinate
+type WeatherProvider interface {
+	Name() string
+	Fetch(q WeatherQuery) (*WeatherReport, error)
+}
+
+// Active provider and the one used when it fails
+var weatherProvider WeatherProvider = NewOpenMeteoProvider("https://api.open-meteo.com/v1/forecast")
+var fallbackProvider WeatherProvider = &MockProvider{}
+
+// newWeatherProvider builds a provider by name (openmeteo, mock or replay)
+func newWeatherProvider(kind, baseURL, replayDir string) (WeatherProvider, error) {
+	switch kind {
+	case "", "openmeteo":
+		return NewOpenMeteoProvider(baseURL), nil
+	case "mock":
+		return &MockProvider{}, nil
+	case "replay":
+		if replayDir == "" {
+			return nil, fmt.Errorf("replay provider needs a directory")
+		}
+		return &ReplayProvider{Dir: replayDir}, nil
+	default:
+		return nil, fmt.Errorf("unknown weather provider: %s", kind)
+	}
+}
+
+// recordingName returns the file name used to record and replay a coordinate
+func recordingName(lat, lon float64) string {
+	return fmt.Sprintf("%.4f_%.4f.json", lat, lon)
+}
+
+// OpenMeteoProvider fetches data from the Open-Meteo forecast API
+type OpenMeteoProvider struct {
+	BaseURL   string
+	Client    *http.Client
+	RecordDir string // when set, raw responses are saved for ReplayProvider
+}
+
+// NewOpenMeteoProvider creates an Open-Meteo provider with a request timeout
+func NewOpenMeteoProvider(baseURL string) *OpenMeteoProvider {
+	if baseURL == "" {
+		baseURL = "https://api.open-meteo.com/v1/forecast"
+	}
+	return &OpenMeteoProvider{
+		BaseURL: baseURL,
+		Client:  &http.Client{Timeout: APITimeout},
+	}
+}
+
+func (p *OpenMeteoProvider) Name() string { return "openmeteo" }
+
+// buildURL returns the Open-Meteo request URL for a query
+func (p *OpenMeteoProvider) buildURL(q WeatherQuery) string {
+	tz := q.Timezone
+	if tz == "" {
+		tz = DefaultTimezone
+	}
+	params := url.Values{}
+	params.Set("latitude", fmt.Sprintf("%.4f", q.Latitude))
+	params.Set("longitude", fmt.Sprintf("%.4f", q.Longitude))
+	params.Set("current", "temperature_2m,apparent_temperature,relative_humidity_2m,weather_code,is_day,wind_speed_10m,wind_direction_10m,wind_gusts_10m,uv_index,precipitation_probability,pressure_msl,surface_pressure,dew_point_2m,visibility,cloud_cover,precipitation,rain,snowfall,snow_depth")
+	params.Set("daily", "weather_code,temperature_2m_max,temperature_2m_min,precipitation_sum,rain_sum,snowfall_sum,wind_speed_10m_max,wind_gusts_10m_max")
+	params.Set("hourly", "temperature_2m,precipitation_probability,precipitation,wind_speed_10m,wind_direction_10m,wind_gusts_10m,weather_code,is_day")
+	params.Set("forecast_days", strconv.Itoa(MaxForecastDays))
+	params.Set("timezone", tz)
+	return p.BaseURL + "?" + params.Encode()
+}
+
+func (p *OpenMeteoProvider) Fetch(q WeatherQuery) (*WeatherReport, error) {
+	url := p.buildURL(q)
+	log.Printf("API URL: %s", url)
+
+	resp, err := p.Client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch weather: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if p.RecordDir != "" {
+		path := filepath.Join(p.RecordDir, recordingName(q.Latitude, q.Longitude))
+		if err := os.WriteFile(path, body, 0644); err != nil {
+			log.Printf("⚠️ Failed to record response to %s: %v", path, err)
+		}
+	}
+
+	return decodeOpenMeteo(bytes.NewReader(body), p.Name(), queryLocation(q))
+}
+
+// queryLocation returns the timezone a query asks the upstream to report local times in
+func queryLocation(q WeatherQuery) *time.Location {
+	return cityLocation(CityCoordinates{Timezone: q.Timezone})
+}
+
+// decodeOpenMeteo parses an Open-Meteo payload requested in timezone loc into a WeatherReport
+func decodeOpenMeteo(r io.Reader, source string, loc *time.Location) (*WeatherReport, error) {
+	var omResponse OpenMeteoResponse
+	if err := json.NewDecoder(r).Decode(&omResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	report := &WeatherReport{
+		Source: source,
+		Current: CurrentConditions{
+			Time:                     omResponse.Current.Time,
+			Temperature:              omResponse.Current.Temperature,
+			ApparentTemperature:      omResponse.Current.ApparentTemperature,
+			Humidity:                 omResponse.Current.Humidity,
+			WindSpeed:                omResponse.Current.WindSpeed,
+			WindGusts:                omResponse.Current.WindGusts,
+			WindDirection:            omResponse.Current.WindDirection,
+			UVIndex:                  omResponse.Current.UVIndex,
+			PrecipitationProbability: omResponse.Current.PrecipitationProbability,
+			PressureMSL:              omResponse.Current.PressureMSL,
+			SurfacePressure:          omResponse.Current.SurfacePressure,
+			DewPoint:                 omResponse.Current.DewPoint,
+			Visibility:               omResponse.Current.Visibility,
+			CloudCover:               omResponse.Current.CloudCover,
+			Precipitation:            omResponse.Current.Precipitation,
+			Rain:                     omResponse.Current.Rain,
+			Snowfall:                 omResponse.Current.Snowfall,
+			SnowDepth:                omResponse.Current.SnowDepth,
+			WeatherCode:              omResponse.Current.WeatherCode,
+			IsDay:                    omResponse.Current.IsDay == nil || *omResponse.Current.IsDay == 1,
+		},
+	}
+
+	daily := omResponse.Daily
+	for i := range daily.Time {
+		if i >= len(daily.WeatherCode) || i >= len(daily.TemperatureMax) || i >= len(daily.TemperatureMin) {
+			break
+		}
+		report.Daily = append(report.Daily, DailyConditions{
+			Date:           daily.Time[i],
+			WeatherCode:    daily.WeatherCode[i],
+			TemperatureMax: daily.TemperatureMax[i],
+			TemperatureMin: daily.TemperatureMin[i],
+
+			PrecipitationSum: optionalAt(daily.PrecipitationSum, i),
+			RainSum:          optionalAt(daily.RainSum, i),
+			SnowfallSum:      optionalAt(daily.SnowfallSum, i),
+			WindSpeedMax:     optionalAt(daily.WindSpeedMax, i),
+			WindGustsMax:     optionalAt(daily.WindGustsMax, i),
+		})
+	}
+
+	// Hourly times are local to the requested timezone, without an offset. Parse them in
+	// that zone rather than utc_offset_seconds, which is only right until the next DST change.
+	hourly := omResponse.Hourly
+	for i := range hourly.Time {
+		if i >= len(hourly.Temperature) || i >= len(hourly.WeatherCode) {
+			break
+		}
+		t, err := time.ParseInLocation("2006-01-02T15:04", hourly.Time[i], loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hourly time %q: %w", hourly.Time[i], err)
+		}
+		hour := HourlyConditions{
+			Time:        t,
+			Temperature: hourly.Temperature[i],
+			WeatherCode: hourly.WeatherCode[i],
+			IsDay:       i >= len(hourly.IsDay) || hourly.IsDay[i] == 1,
+		}
+		if i < len(hourly.PrecipitationProbability) {
+			hour.PrecipitationProbability = hourly.PrecipitationProbability[i]
+		}
+		if i < len(hourly.Precipitation) {
+			hour.Precipitation = hourly.Precipitation[i]
+		}
+		if i < len(hourly.WindSpeed) {
+			hour.WindSpeed = hourly.WindSpeed[i]
+		}
+		if i < len(hourly.WindDirection) {
+			hour.WindDirection = hourly.WindDirection[i]
+		}
+		if i < len(hourly.WindGusts) {
+			hour.WindGusts = hourly.WindGusts[i]
+		}
+		report.Hourly = append(report.Hourly, hour)
+	}
+
+	return report, nil
+}
+
+// optionalAt returns the i-th value of an optional daily series, nil when it is missing
+func optionalAt(values []*float64, i int) *float64 {
+	if i < len(values) {
+		return values[i]
+	}
+	return nil
+}
+
+// ReplayProvider serves Open-Meteo responses recorded on disk, one file per coordinate
+type ReplayProvider struct {
+	Dir string
+}
+
+func (p *ReplayProvider) Name() string { return "replay" }
+
+func (p *ReplayProvider) Fetch(q WeatherQuery) (*WeatherReport, error) {
+	path := filepath.Join(p.Dir, recordingName(q.Latitude, q.Longitude))
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("no recorded response: %w", err)
+	}
+	defer f.Close()
+	return decodeOpenMeteo(f, p.Name(), queryLocation(q))
+}
+
+// MockProvider builds reports from the locations mock map, using the nearest city
+type MockProvider struct{}
+
+func (p *MockProvider) Name() string { return "mock" }
+
+func (p *MockProvider) Fetch(q WeatherQuery) (*WeatherReport, error) {
+	cityKey, ok := nearestMockCity(q.Latitude, q.Longitude)
+	if !ok {
+		return nil, fmt.Errorf("no mock data available")
+	}
+	base, _ := mockBaseline(cityKey)
+	code := conditionToWMOCode(base.ConditionID)
+	if code < 0 {
+		code = 3
+	}
+
+	loc := queryLocation(q)
+	now := time.Now().In(loc)
+	apparent := float64(base.FeelsLike)
+	gusts := math.Round(float64(base.WindSpeed) * 1.4)
+	pressure := 1013.25 // standard atmosphere
+
+	report := &WeatherReport{
+		Source: p.Name(),
+		Current: CurrentConditions{
+			Time:                now.Format("2006-01-02T15:04"),
+			Temperature:         float64(base.Temperature),
+			ApparentTemperature: &apparent,
+			Humidity:            base.Humidity,
+			WindSpeed:           float64(base.WindSpeed),
+			WindDirection:       45,
+			WindGusts:           &gusts,
+			PressureMSL:         &pressure,
+			SurfacePressure:     &pressure,
+			WeatherCode:         code,
+			IsDay:               isDaytime(now),
+		},
+	}
+
+	precipChance, precip := 10, 0.0
+	if code >= 51 {
+		precipChance, precip = 70, 0.8
+	}
+	report.Current.Precipitation = &precip
+
+	// Deterministic shape around the baseline so repeated calls agree
+	daySum, windMax := precip*24, float64(base.WindSpeed)
+	for i := 0; i < MaxForecastDays; i++ {
+		report.Daily = append(report.Daily, DailyConditions{
+			Date:             now.AddDate(0, 0, i).Format("2006-01-02"),
+			WeatherCode:      code,
+			TemperatureMax:   float64(base.Temperature + 2 + i%3),
+			TemperatureMin:   float64(base.Temperature - 4 + i%2),
+			PrecipitationSum: &daySum,
+			WindSpeedMax:     &windMax,
+			WindGustsMax:     &gusts,
+		})
+	}
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	for h := 0; h < 7*24; h++ {
+		t := midnight.Add(time.Duration(h) * time.Hour)
+		// Coldest around 04:00, warmest around 16:00
+		swing := -3 * math.Cos(2*math.Pi*float64(t.Hour()-4)/24)
+		report.Hourly = append(report.Hourly, HourlyConditions{
+			Time:                     t,
+			Temperature:              math.Round((float64(base.Temperature)+swing)*10) / 10,
+			PrecipitationProbability: precipChance,
+			Precipitation:            precip,
+			WindSpeed:                float64(base.WindSpeed),
+			WindDirection:            45,
+			WindGusts:                gusts,
+			WeatherCode:              code,
+			IsDay:                    isDaytime(t),
+		})
+	}
+
+	return report, nil
+}
+
+// nearestMockCity returns the mock location closest to a coordinate
+func nearestMockCity(lat, lon float64) (string, bool) {
+	cityLock.RLock()
+	defer cityLock.RUnlock()
+
+	best := ""
+	bestDist := math.MaxFloat64
+	for key, coords := range cityCoordinates {
+		if _, ok := locations[key]; !ok {
+			continue
+		}
+		d := math.Hypot(coords.Latitude-lat, coords.Longitude-lon)
+		if d < bestDist {
+			best, bestDist = key, d
+		}
+	}
+	return best, best != ""
+}
+
+// isDaytime is the mock's stand-in for sunrise and sunset: 06:00 to 20:00 local time
+func isDaytime(t time.Time) bool {
+	return t.Hour() >= 6 && t.Hour() < 20
+}
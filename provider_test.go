@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDecodeOpenMeteoAcrossDST checks hourly times after a DST change keep their wall clock
+func TestDecodeOpenMeteoAcrossDST(t *testing.T) {
+	payload := `{
+		"utc_offset_seconds": 7200,
+		"current": {"time": "2026-10-24T12:00", "temperature_2m": 15, "weather_code": 3},
+		"hourly": {
+			"time": ["2026-10-24T10:00", "2026-10-25T10:00"],
+			"temperature_2m": [14, 12],
+			"weather_code": [3, 61]
+		}
+	}`
+	loc, err := time.LoadLocation("Europe/Zagreb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := decodeOpenMeteo(strings.NewReader(payload), "test", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2026-10-24T10:00:00+02:00", "2026-10-25T10:00:00+01:00"}
+	if len(report.Hourly) != len(want) {
+		t.Fatalf("got %d hours, want %d", len(report.Hourly), len(want))
+	}
+	for i, h := range report.Hourly {
+		if got := h.Time.Format(time.RFC3339); got != want[i] {
+			t.Errorf("hour %d = %s, want %s", i, got, want[i])
+		}
+	}
+}
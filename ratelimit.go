@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/maphash"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Per-client rate limits
+var (
+	RateLimitBurst     = 30
+	RateLimitPerMinute = MaxRequests
+)
+
+const (
+	RateLimitEvictInterval = time.Minute
+	rateLimitShards        = 32 // buckets are split so clients rarely wait on each other's lock
+)
+
+// tokenBucket holds one client's tokens as of last
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiterShard holds the buckets of the clients hashed to it
+type limiterShard struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// clientLimiter gives every client its own token bucket
+type clientLimiter struct {
+	burst  float64
+	perSec float64
+	seed   maphash.Seed
+	shards [rateLimitShards]limiterShard
+}
+
+func newClientLimiter(burst, perMinute int) *clientLimiter {
+	l := &clientLimiter{
+		burst:  float64(burst),
+		perSec: float64(perMinute) / 60,
+		seed:   maphash.MakeSeed(),
+	}
+	for i := range l.shards {
+		l.shards[i].buckets = make(map[string]*tokenBucket)
+	}
+	return l
+}
+
+// shard returns the shard holding a client's bucket
+func (l *clientLimiter) shard(client string) *limiterShard {
+	return &l.shards[maphash.String(l.seed, client)%rateLimitShards]
+}
+
+var requestLimiter = newClientLimiter(RateLimitBurst, RateLimitPerMinute)
+
+// rateDecision is the outcome of one take, with what the headers need
+type rateDecision struct {
+	allowed    bool
+	remaining  int
+	reset      time.Duration // until the bucket is full again
+	retryAfter time.Duration // until the next token, when denied
+}
+
+// take spends one token from the client's bucket if it has one
+func (l *clientLimiter) take(client string, now time.Time) rateDecision {
+	s := l.shard(client)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		s.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.perSec)
+	b.last = now
+
+	d := rateDecision{allowed: b.tokens >= 1}
+	if d.allowed {
+		b.tokens--
+	} else {
+		d.retryAfter = l.refillTime(1 - b.tokens)
+	}
+	d.remaining = int(b.tokens)
+	d.reset = l.refillTime(l.burst - b.tokens)
+	return d
+}
+
+// refillTime is how long it takes to earn the given number of tokens
+func (l *clientLimiter) refillTime(tokens float64) time.Duration {
+	if l.perSec <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / l.perSec * float64(time.Second))
+}
+
+// evictIdle drops buckets that have refilled completely, since a new bucket is identical
+func (l *clientLimiter) evictIdle(now time.Time) int {
+	full := l.refillTime(l.burst)
+
+	evicted := 0
+	for i := range l.shards {
+		s := &l.shards[i]
+		s.mu.Lock()
+		for client, b := range s.buckets {
+			if now.Sub(b.last) >= full {
+				delete(s.buckets, client)
+				evicted++
+			}
+		}
+		s.mu.Unlock()
+	}
+	return evicted
+}
+
+// runRateLimitEviction evicts idle buckets until ctx is cancelled
+func runRateLimitEviction(ctx context.Context) {
+	ticker := time.NewTicker(RateLimitEvictInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			requestLimiter.evictIdle(now)
+		}
+	}
+}
+
+// trustedProxies are the addresses allowed to set X-Forwarded-For
+var trustedProxies []netip.Prefix
+
+// parseTrustedProxies reads a comma separated list of IPs and CIDR ranges
+func parseTrustedProxies(list string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				log.Printf("⚠️ Ignoring invalid trusted proxy %q: %v", item, err)
+				continue
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			log.Printf("⚠️ Ignoring invalid trusted proxy %q: %v", item, err)
+			continue
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+}
+
+// isTrustedProxy reports whether addr is one of the trusted proxies
+func isTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the caller's address. X-Forwarded-For is only believed when the
+// connection comes from a trusted proxy, and then read right to left up to the
+// first address that is not a proxy itself.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !isTrustedProxy(addr) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop
+		if !isTrustedProxy(hop) {
+			break
+		}
+	}
+	return addr.Unmap().String()
+}
+
+// requestAPIKey returns the API key sent with a request, if any. Keys are only read from
+// headers; in the URL they would end up in access logs, proxy caches and Referer headers.
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return key
+	}
+	return ""
+}
+
+// rateLimitClient identifies the bucket a request is charged to: its API key, or its IP
+func rateLimitClient(r *http.Request) string {
+	if key := requestAPIKey(r); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:8])
+	}
+	return "ip:" + clientIP(r)
+}
+
+// rateLimit charges the request to its client's bucket and sets the X-RateLimit headers
+func rateLimit(w http.ResponseWriter, r *http.Request) bool {
+	d := requestLimiter.take(rateLimitClient(r), time.Now())
+
+	h := w.Header()
+	h.Set("X-RateLimit-Limit", strconv.Itoa(int(requestLimiter.burst)))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(d.remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(d.reset.Seconds()))))
+
+	if !d.allowed {
+		h.Set("Retry-After", strconv.Itoa(int(math.Ceil(d.retryAfter.Seconds()))))
+		writeJSONError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	RefreshJitter     = 0.1 // ±10% of the refresh interval
+	RefreshBackoffMin = 30 * time.Second
+	RefreshBackoffMax = 30 * time.Minute
+)
+
+// refreshState tracks when a city is next due and how often it has failed
+type refreshState struct {
+	next     time.Time
+	failures int
+	running  bool
+}
+
+// refreshScheduler refreshes every registry city in the background,
+// so handlers only ever read what is already cached
+type refreshScheduler struct {
+	interval time.Duration
+	mu       sync.Mutex
+	states   map[string]*refreshState
+}
+
+func newRefreshScheduler(interval time.Duration) *refreshScheduler {
+	return &refreshScheduler{
+		interval: interval,
+		states:   make(map[string]*refreshState),
+	}
+}
+
+// jittered spreads refreshes so cities do not all hit the upstream at once
+func (s *refreshScheduler) jittered() time.Duration {
+	spread := float64(s.interval) * RefreshJitter
+	return s.interval + time.Duration((rand.Float64()*2-1)*spread)
+}
+
+// backoff returns the retry delay after a number of consecutive failures
+func backoff(failures int) time.Duration {
+	d := RefreshBackoffMin
+	for i := 1; i < failures && d < RefreshBackoffMax; i++ {
+		d *= 2
+	}
+	return min(d, RefreshBackoffMax)
+}
+
+// Run checks for due cities every second until ctx is cancelled
+func (s *refreshScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.tick(now)
+		}
+	}
+}
+
+// tick syncs the schedule with the registry and starts refreshes that are due
+func (s *refreshScheduler) tick(now time.Time) {
+	keys := cityKeys()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	known := make(map[string]bool, len(keys))
+	for _, city := range keys {
+		known[city] = true
+		state, ok := s.states[city]
+		if !ok {
+			// New cities were just warmed, so start them somewhere in the first interval
+			state = &refreshState{next: now.Add(time.Duration(rand.Int63n(int64(s.interval))))}
+			s.states[city] = state
+		}
+		if state.running || now.Before(state.next) {
+			continue
+		}
+		state.running = true
+		go s.refresh(city)
+	}
+
+	for city := range s.states {
+		if !known[city] {
+			delete(s.states, city)
+		}
+	}
+}
+
+// refresh runs one refresh and schedules the next one
+func (s *refreshScheduler) refresh(city string) {
+	err := refreshCity(city)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[city]
+	if !ok {
+		return
+	}
+	state.running = false
+	if err != nil {
+		state.failures++
+		delay := backoff(state.failures)
+		state.next = time.Now().Add(delay)
+		log.Printf("⚠️ Background refresh failed for %s (%d in a row): %v. Retrying in %s", city, state.failures, err, delay)
+		return
+	}
+	state.failures = 0
+	state.next = time.Now().Add(s.jittered())
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+const (
+	ReadHeaderTimeout = 5 * time.Second
+	ReadTimeout       = 15 * time.Second
+	WriteTimeout      = 30 * time.Second // long history exports included
+	IdleTimeout       = 2 * time.Minute
+	ShutdownTimeout   = 20 * time.Second // how long in-flight requests get to finish
+)
+
+// serverCtx is cancelled by POST /shutdown; main also cancels it on SIGINT/SIGTERM
+var serverCtx, requestShutdown = context.WithCancel(context.Background())
+
+// newHTTPServer wraps the default mux with timeouts so slow clients cannot hold connections forever
+func newHTTPServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           http.DefaultServeMux,
+		ReadHeaderTimeout: ReadHeaderTimeout,
+		ReadTimeout:       ReadTimeout,
+		WriteTimeout:      WriteTimeout,
+		IdleTimeout:       IdleTimeout,
+	}
+}
+
+// Handler for POST /shutdown - starts a graceful shutdown; the response is sent before draining
+func shutdownHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	log.Printf("🛑 Shutdown requested by %s", clientIP(r))
+	setCommonHeaders(w)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "shutting down"})
+	requestShutdown()
+}
+
+// serveUntilShutdown runs the server until ctx is cancelled, then drains
+// in-flight requests and flushes history and cache state to dataDir
+func serveUntilShutdown(ctx context.Context, server *http.Server, dataDir string) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("🛑 Shutting down, waiting up to %s for in-flight requests...", ShutdownTimeout)
+	drainCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	err := server.Shutdown(drainCtx)
+	if err != nil {
+		log.Printf("⚠️ Requests still running after %s: %v", ShutdownTimeout, err)
+	}
+	if serveErr := <-errs; !errors.Is(serveErr, http.ErrServerClosed) {
+		err = errors.Join(err, serveErr)
+	}
+
+	if closeErr := historyStore.Close(); closeErr != nil {
+		log.Printf("⚠️ Failed to close history store: %v", closeErr)
+		err = errors.Join(err, closeErr)
+	}
+	if saveErr := saveCacheState(filepath.Join(dataDir, "cache.json")); saveErr != nil {
+		log.Printf("⚠️ Failed to save cache state: %v", saveErr)
+		err = errors.Join(err, saveErr)
+	}
+	log.Printf("✓ Server stopped")
+	return err
+}
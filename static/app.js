@@ -90,7 +90,7 @@ function updateCardData(city, data) {
 
 function loadWeather(location) {
     lastViewedLocation = location;
-    fetch('/api/weather/' + location)
+    fetch('/api/weather/' + location + '?units=metric')
         .then(r => r.json())
         .then(data => {
             lastRefreshTime = new Date();
@@ -112,7 +112,7 @@ function loadWeather(location) {
 
 function loadForecast(location) {
     lastViewedLocation = location;
-    fetch('/api/forecast/' + location)
+    fetch('/api/forecast/' + location + '?units=metric')
         .then(r => r.json())
         .then(data => {
             lastRefreshTime = new Date();
@@ -120,7 +120,7 @@ function loadForecast(location) {
             let cityName = location.charAt(0).toUpperCase() + location.slice(1);
             let msg = 'Prognoza od 5 dana za ' + cityName + ':\n\n';
             data.Forecast.forEach(day => {
-                msg += day.Date + ': ' + day.Emoji + ' ' + day.High + '°C / ' + day.Low + '°C - ' + day.Condition + '\n';
+                msg += day.Day + ': ' + day.Emoji + ' ' + day.High + '°C / ' + day.Low + '°C - ' + day.Condition + '\n';
             });
             alert(msg);
         })
@@ -135,7 +135,7 @@ function startAutoRefresh() {
     const refreshInterval = 15 * 60 * 1000;
     setInterval(function() {
         if (lastViewedLocation) {
-            fetch('/api/weather/' + lastViewedLocation)
+            fetch('/api/weather/' + lastViewedLocation + '?units=metric')
                 .then(r => r.json())
                 .then(data => {
                     lastRefreshTime = new Date();
@@ -159,7 +159,7 @@ function loadAllCitiesData() {
     const cities = ['zagreb', 'split', 'dubrovnik', 'rijeka', 'zadar', 'osijek'];
     
     cities.forEach(city => {
-        const url = '/api/weather/' + city;
+        const url = '/api/weather/' + city + '?units=metric';
         console.log('Fetching:', url);
         
         // Use regular fetch to debug the issue
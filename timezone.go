@@ -0,0 +1,141 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TimezoneRadiusKm   = 300  // how far a registry city lends its timezone to coordinate lookups
+	ZoneCenterRadiusKm = 1500 // beyond this from any tzdb principal city we are at sea
+)
+
+// zone1970.tab from the tz database (public domain) lists every zone with its principal city
+//
+//go:embed zone1970.tab
+var zoneTable string
+
+// zoneCenter is the principal city of an IANA zone
+type zoneCenter struct {
+	name     string
+	lat, lon float64
+}
+
+// zoneCenters parses zoneTable once
+var zoneCenters = sync.OnceValue(func() []zoneCenter {
+	var centers []zoneCenter
+	for _, line := range strings.Split(zoneTable, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lat, lon, ok := parseISO6709(fields[1])
+		if !ok {
+			continue
+		}
+		if _, err := time.LoadLocation(fields[2]); err == nil {
+			centers = append(centers, zoneCenter{name: fields[2], lat: lat, lon: lon})
+		}
+	}
+	return centers
+})
+
+// parseISO6709 parses the ±DDMM±DDDMM or ±DDMMSS±DDDMMSS coordinates of zone1970.tab
+func parseISO6709(s string) (lat, lon float64, ok bool) {
+	split := strings.LastIndexAny(s, "+-")
+	if split <= 0 {
+		return 0, 0, false
+	}
+	lat, ok1 := parseDMS(s[:split], 2)
+	lon, ok2 := parseDMS(s[split:], 3)
+	return lat, lon, ok1 && ok2
+}
+
+// parseDMS parses a signed degrees-minutes[-seconds] value with the given number of degree digits
+func parseDMS(s string, degreeDigits int) (float64, bool) {
+	sign, digits := 1.0, s[1:]
+	if s[0] == '-' {
+		sign = -1
+	}
+	if len(digits) != degreeDigits+2 && len(digits) != degreeDigits+4 {
+		return 0, false
+	}
+	value, scale := 0.0, 1.0
+	for i := 0; i < len(digits); {
+		n := 2
+		if i == 0 {
+			n = degreeDigits
+		}
+		part, err := strconv.Atoi(digits[i : i+n])
+		if err != nil {
+			return 0, false
+		}
+		value += float64(part) / scale
+		scale *= 60
+		i += n
+	}
+	return sign * value, true
+}
+
+// inferTimezone picks an IANA timezone for a coordinate without a network call: the zone of
+// the nearest registry city within TimezoneRadiusKm, else the zone whose tzdb principal city
+// is nearest, else (far out at sea) the nautical zone of the longitude. Near a border the
+// neighbouring zone may win, since principal cities stand in for the real boundaries.
+func inferTimezone(lat, lon float64) string {
+	if nearest := nearestCity(lat, lon); nearest != nil && nearest.DistanceKm <= TimezoneRadiusKm {
+		if city, ok := lookupCity(nearest.Key); ok && city.Timezone != "" {
+			return city.Timezone
+		}
+	}
+	best, bestKm := "", math.MaxFloat64
+	for _, z := range zoneCenters() {
+		if d := haversineKm(lat, lon, z.lat, z.lon); d < bestKm {
+			best, bestKm = z.name, d
+		}
+	}
+	if bestKm <= ZoneCenterRadiusKm {
+		return best
+	}
+	return nauticalTimezone(lon)
+}
+
+// nauticalTimezone returns the Etc/GMT zone of a longitude, 15° per hour, without DST.
+// Etc/GMT names have inverted signs: UTC+1 is Etc/GMT-1.
+func nauticalTimezone(lon float64) string {
+	offset := int(math.Round(lon / 15))
+	switch {
+	case offset == 0:
+		return "Etc/GMT"
+	case offset > 0:
+		return fmt.Sprintf("Etc/GMT-%d", min(offset, 12))
+	default:
+		return fmt.Sprintf("Etc/GMT+%d", min(-offset, 12))
+	}
+}
+
+// localTimestamp formats a time as RFC 3339 with the offset of loc
+func localTimestamp(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(time.RFC3339)
+}
+
+// bucketStartIn aligns a time to step in loc, so daily buckets start at local midnight.
+// Steps shorter than a day restart at every midnight; multi-day steps count whole local days.
+func bucketStartIn(t time.Time, step time.Duration, loc *time.Location) time.Time {
+	const day = 24 * time.Hour
+	t = t.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	if step < day {
+		return midnight.Add(t.Sub(midnight).Truncate(step))
+	}
+	if step%day != 0 {
+		return t.Truncate(step)
+	}
+	days := int(step / day)
+	epochDay := int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / int64(day/time.Second))
+	return midnight.AddDate(0, 0, -(epochDay % days))
+}
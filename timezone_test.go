@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInferTimezone(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     string
+	}{
+		{"New York", 40.7, -74, "America/New_York"},
+		{"Denver", 39.74, -104.99, "America/Denver"},
+		{"Kolkata", 22.57, 88.36, "Asia/Kolkata"},
+		{"Sydney", -33.87, 151.21, "Australia/Sydney"},
+		{"North Pacific", 30, -140, "Etc/GMT+9"},
+		{"North Atlantic", 30, -45, "Etc/GMT+3"},
+	}
+	for _, tt := range tests {
+		if got := inferTimezone(tt.lat, tt.lon); got != tt.want {
+			t.Errorf("%s: inferTimezone(%v, %v) = %s, want %s", tt.name, tt.lat, tt.lon, got, tt.want)
+		}
+	}
+}
+
+func TestBucketStartIn(t *testing.T) {
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+	zagreb, _ := time.LoadLocation("Europe/Zagreb")
+	tests := []struct {
+		t    time.Time
+		step time.Duration
+		want time.Time
+	}{
+		// 10:15 in a +05:30 zone is in the 10:00 local hour, not 09:30
+		{time.Date(2026, 10, 16, 10, 15, 0, 0, kolkata), time.Hour, time.Date(2026, 10, 16, 10, 0, 0, 0, kolkata)},
+		{time.Date(2026, 10, 16, 0, 45, 0, 0, kolkata), 24 * time.Hour, time.Date(2026, 10, 16, 0, 0, 0, 0, kolkata)},
+		// 25-hour day at the end of DST: the repeated 02:00 hours stay apart
+		{time.Date(2026, 10, 25, 2, 30, 0, 0, zagreb).Add(time.Hour), time.Hour, time.Date(2026, 10, 25, 2, 0, 0, 0, zagreb).Add(time.Hour)},
+		{time.Date(2026, 10, 25, 23, 59, 0, 0, zagreb), 24 * time.Hour, time.Date(2026, 10, 25, 0, 0, 0, 0, zagreb)},
+	}
+	for _, tt := range tests {
+		if got := bucketStartIn(tt.t, tt.step, tt.t.Location()); !got.Equal(tt.want) {
+			t.Errorf("bucketStartIn(%s, %s) = %s, want %s", tt.t, tt.step, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// TrendWindow is how much history each trend fit uses
+var TrendWindow = 3 * time.Hour
+
+const (
+	TrendMinSamples    = 3   // fewer samples in the window report no trend at all
+	TrendMinConfidence = 0.5 // fits explaining less of the variance than this count as stable
+)
+
+// trendThresholds is the smallest slope per hour that counts as a change for each field
+var trendThresholds = map[string]float64{
+	"temperature": 0.3, // °C/h
+	"humidity":    2,   // %/h
+	"pressure":    0.5, // hPa/h
+}
+
+// Trend is a least-squares fit of one field over the trend window
+type Trend struct {
+	Direction  string  // rising, falling or stable
+	Slope      float64 // units per hour (°C/h, %/h, hPa/h)
+	Confidence float64 // R² of the fit, 0 to 1
+	Samples    int
+	Reversal   bool // the previous window moved the other way
+}
+
+// WeatherTrends holds the trends of a city; fields without enough history are null
+type WeatherTrends struct {
+	Window      string
+	Temperature *Trend
+	Humidity    *Trend
+	Pressure    *Trend
+}
+
+// fitTrend fits a line through the samples of field, with time in hours
+func fitTrend(obs []Observation, field string) (slope, r2 float64, n int) {
+	var xs, ys []float64
+	for _, o := range obs {
+		if v, ok := o.Values[field]; ok {
+			xs = append(xs, o.Time.Sub(obs[0].Time).Hours())
+			ys = append(ys, v)
+		}
+	}
+	n = len(xs)
+	if n < 2 {
+		return 0, 0, n
+	}
+
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= float64(n)
+	meanY /= float64(n)
+
+	var sxx, sxy, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return 0, 0, n
+	}
+	slope = sxy / sxx
+	if syy == 0 {
+		// A perfectly flat series is fully explained by a zero slope
+		return slope, 1, n
+	}
+	return slope, sxy * sxy / (sxx * syy), n
+}
+
+// classifyTrend turns a fit into a Trend with a direction
+func classifyTrend(obs []Observation, field string) Trend {
+	slope, r2, n := fitTrend(obs, field)
+	t := Trend{
+		Direction:  "stable",
+		Slope:      math.Round(slope*100) / 100,
+		Confidence: math.Round(r2*100) / 100,
+		Samples:    n,
+	}
+	if n >= TrendMinSamples && r2 >= TrendMinConfidence && math.Abs(slope) >= trendThresholds[field] {
+		if slope > 0 {
+			t.Direction = "rising"
+		} else {
+			t.Direction = "falling"
+		}
+	}
+	return t
+}
+
+// analyzeTrend fits field over the window ending at now and flags a reversal
+// when the window before it had the opposite direction. It returns nil below TrendMinSamples.
+func analyzeTrend(city, field string, now time.Time) *Trend {
+	obs, err := historyStore.Observations(city, now.Add(-2*TrendWindow), now.Add(time.Second))
+	if err != nil {
+		return nil
+	}
+	split := len(obs)
+	for i, o := range obs {
+		if !o.Time.Before(now.Add(-TrendWindow)) {
+			split = i
+			break
+		}
+	}
+
+	current := classifyTrend(obs[split:], field)
+	if current.Samples < TrendMinSamples {
+		return nil
+	}
+	previous := classifyTrend(obs[:split], field)
+	current.Reversal = current.Direction != "stable" && previous.Direction != "stable" && current.Direction != previous.Direction
+	return &current
+}
+
+// analyzeTrends computes the trends reported with a city's current weather
+func analyzeTrends(city string, now time.Time) *WeatherTrends {
+	return &WeatherTrends{
+		Window:      TrendWindow.String(),
+		Temperature: analyzeTrend(city, "temperature", now),
+		Humidity:    analyzeTrend(city, "humidity", now),
+		Pressure:    analyzeTrend(city, "pressure", now),
+	}
+}
+
+// getTemperatureTrend returns the trend (up/down/stable)
+func getTemperatureTrend(city string) string {
+	if t := analyzeTrend(city, "temperature", time.Now()); t != nil {
+		return t.Direction
+	}
+	return "stable"
+}
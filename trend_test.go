@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestAnalyzeTrendMinSamples checks a field is only reported once the window holds TrendMinSamples
+func TestAnalyzeTrendMinSamples(t *testing.T) {
+	saved := historyStore
+	historyStore = newMemoryHistoryStore()
+	t.Cleanup(func() { historyStore = saved })
+
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < TrendMinSamples; i++ {
+		if got := analyzeTrend("trendcity", "temperature", now); got != nil {
+			t.Fatalf("with %d samples got %+v, want nil", i, got)
+		}
+		obs := Observation{Time: now.Add(time.Duration(i-TrendMinSamples+1) * time.Hour), Values: map[string]float64{"temperature": float64(10 + i)}}
+		if err := historyStore.Append("trendcity", obs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := analyzeTrend("trendcity", "temperature", now)
+	if got == nil || got.Samples != TrendMinSamples || got.Direction != "rising" {
+		t.Errorf("with %d samples got %+v, want a rising trend", TrendMinSamples, got)
+	}
+}
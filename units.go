@@ -0,0 +1,219 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strings"
+)
+
+// Units describes the unit system of a response; every API body carries one.
+// Values are converted from the metric data the providers deliver.
+type Units struct {
+	System        string
+	Temperature   string
+	WindSpeed     string
+	Precipitation string
+	Snow          string // snowfall and snow depth
+	Pressure      string
+	Distance      string // visibility
+}
+
+var unitSystems = map[string]Units{
+	"metric":   {System: "metric", Temperature: "°C", WindSpeed: "km/h", Precipitation: "mm", Snow: "cm", Pressure: "hPa", Distance: "km"},
+	"imperial": {System: "imperial", Temperature: "°F", WindSpeed: "mph", Precipitation: "in", Snow: "in", Pressure: "inHg", Distance: "mi"},
+	"si":       {System: "si", Temperature: "°C", WindSpeed: "m/s", Precipitation: "mm", Snow: "cm", Pressure: "hPa", Distance: "km"},
+	"nautical": {System: "nautical", Temperature: "°C", WindSpeed: "kn", Precipitation: "mm", Snow: "cm", Pressure: "hPa", Distance: "NM"},
+}
+
+var metricUnits = unitSystems["metric"]
+
+// imperialRegions are the countries that still measure temperature in Fahrenheit
+var imperialRegions = map[string]bool{"US": true, "LR": true, "MM": true, "BS": true, "BZ": true, "KY": true, "PW": true}
+
+// unitsForLanguage picks a default unit system from Accept-Language, e.g. en-US gets imperial
+func unitsForLanguage(acceptLanguage string) Units {
+	first, _, _ := strings.Cut(acceptLanguage, ",")
+	tag, _, _ := strings.Cut(strings.TrimSpace(first), ";")
+	parts := strings.Split(tag, "-")
+	if len(parts) > 1 && imperialRegions[strings.ToUpper(parts[len(parts)-1])] {
+		return unitSystems["imperial"]
+	}
+	return metricUnits
+}
+
+// requestUnits reads ?units=, defaulting from Accept-Language. It writes a 400 and
+// returns false for an unknown system.
+func requestUnits(w http.ResponseWriter, r *http.Request) (Units, bool) {
+	name := strings.ToLower(r.URL.Query().Get("units"))
+	if name == "" {
+		varyOn(w, "Accept-Language")
+		return unitsForLanguage(r.Header.Get("Accept-Language")), true
+	}
+	u, ok := unitSystems[name]
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "units must be metric, imperial, si or nautical")
+		return Units{}, false
+	}
+	return u, true
+}
+
+// temp converts °C
+func (u Units) temp(c float64) float64 {
+	if u.Temperature == "°F" {
+		return c*9/5 + 32
+	}
+	return c
+}
+
+// wind converts km/h
+func (u Units) wind(kmh float64) float64 {
+	switch u.WindSpeed {
+	case "mph":
+		return kmh / 1.609344
+	case "m/s":
+		return kmh / 3.6
+	case "kn":
+		return kmh / 1.852
+	}
+	return kmh
+}
+
+// precip converts mm
+func (u Units) precip(mm float64) float64 {
+	if u.Precipitation == "in" {
+		return mm / 25.4
+	}
+	return mm
+}
+
+// snow converts cm
+func (u Units) snow(cm float64) float64 {
+	if u.Snow == "in" {
+		return cm / 2.54
+	}
+	return cm
+}
+
+// distance converts km
+func (u Units) distance(km float64) float64 {
+	switch u.Distance {
+	case "mi":
+		return km / 1.609344
+	case "NM":
+		return km / 1.852
+	}
+	return km
+}
+
+// optional converts an optional reading, rounding to the given decimals
+func optional(v *float64, convert func(float64) float64, decimals int) *float64 {
+	if v == nil {
+		return nil
+	}
+	scale := math.Pow(10, float64(decimals))
+	converted := math.Round(convert(*v)*scale) / scale
+	return &converted
+}
+
+// pressure converts hPa
+func (u Units) pressure(hPa float64) float64 {
+	if u.Pressure == "inHg" {
+		return math.Round(hPa/33.8639*100) / 100
+	}
+	return hPa
+}
+
+// round1 rounds to one decimal, enough for converted readings
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+// roundInt rounds a converted reading for the whole-number fields of WeatherData
+func roundInt(v float64) int {
+	return int(math.Round(v))
+}
+
+// weather converts current conditions from their unrounded readings when it has them
+func (u Units) weather(d WeatherData) WeatherData {
+	if u == metricUnits {
+		return d
+	}
+	r := metricReadings{float64(d.Temperature), float64(d.FeelsLike), float64(d.WindSpeed), float64(d.WindGusts)}
+	if d.readings != nil {
+		r = *d.readings
+	}
+	d.Temperature = roundInt(u.temp(r.Temperature))
+	d.FeelsLike = roundInt(u.temp(r.FeelsLike))
+	d.WindSpeed = roundInt(u.wind(r.WindSpeed))
+	d.WindGusts = roundInt(u.wind(r.WindGusts))
+	d.Pressure = u.pressure(d.Pressure)
+	d.SurfacePressure = u.pressure(d.SurfacePressure)
+	d.DewPoint = optional(d.DewPoint, u.temp, 1)
+	d.Visibility = optional(d.Visibility, u.distance, 1)
+	d.Precipitation = optional(d.Precipitation, u.precip, 2)
+	d.Rain = optional(d.Rain, u.precip, 2)
+	d.Snowfall = optional(d.Snowfall, u.snow, 2)
+	d.SnowDepth = optional(d.SnowDepth, u.snow, 1)
+	if t := d.PressureTendency; t != nil {
+		converted := *t
+		converted.Change = u.pressure(t.Change)
+		d.PressureTendency = &converted
+	}
+	return d
+}
+
+// forecast converts a weather or forecast body, copying what it changes
+func (u Units) forecast(body WeatherForecast) WeatherForecast {
+	body.Units = u
+	if u == metricUnits {
+		return body
+	}
+	body.Current = u.weather(body.Current)
+	days := make([]ForecastDay, len(body.Forecast))
+	for i, day := range body.Forecast {
+		day.High = roundInt(u.temp(day.high))
+		day.Low = roundInt(u.temp(day.low))
+		day.PrecipitationSum = optional(day.PrecipitationSum, u.precip, 2)
+		day.RainSum = optional(day.RainSum, u.precip, 2)
+		day.SnowfallSum = optional(day.SnowfallSum, u.snow, 1)
+		day.WindSpeedMax = optional(day.WindSpeedMax, u.wind, 1)
+		day.WindGustsMax = optional(day.WindGustsMax, u.wind, 1)
+		days[i] = day
+	}
+	body.Forecast = days
+	return body
+}
+
+// hourly converts hourly forecasts in place
+func (u Units) hourly(hours []HourlyForecast) {
+	if u == metricUnits {
+		return
+	}
+	for i := range hours {
+		hours[i].Temperature = round1(u.temp(hours[i].Temperature))
+		hours[i].WindSpeed = round1(u.wind(hours[i].WindSpeed))
+		hours[i].WindGusts = round1(u.wind(hours[i].WindGusts))
+		hours[i].Precipitation = math.Round(u.precip(hours[i].Precipitation)*100) / 100
+	}
+}
+
+// historyPoints converts history buckets in place
+func (u Units) historyPoints(points []HistoryPoint) {
+	if u == metricUnits {
+		return
+	}
+	for i := range points {
+		if t := points[i].Temperature; t != nil {
+			v := round1(u.temp(*t))
+			points[i].Temperature = &v
+		}
+		if w := points[i].WindSpeed; w != nil {
+			v := round1(u.wind(*w))
+			points[i].WindSpeed = &v
+		}
+		if p := points[i].Pressure; p != nil {
+			v := u.pressure(*p)
+			points[i].Pressure = &v
+		}
+	}
+}
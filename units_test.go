@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+// TestUnitsRoundOnce checks conversions start from the provider's readings, not rounded values
+func TestUnitsRoundOnce(t *testing.T) {
+	report := &WeatherReport{
+		Current: CurrentConditions{Time: "2026-10-16T12:00", Temperature: 14.9, Humidity: 60, WindSpeed: 10.4, WeatherCode: 0, IsDay: true},
+		Daily:   []DailyConditions{{Date: "2026-10-16", TemperatureMax: 14.9, TemperatureMin: 4.4}},
+	}
+	data := weatherDataFromReport(CityCoordinates{Name: "Test", Timezone: "Europe/Zagreb"}, report)
+	if data.Temperature != 15 {
+		t.Errorf("metric Temperature = %d, want 15", data.Temperature)
+	}
+
+	imperial := unitSystems["imperial"]
+	body := imperial.forecast(WeatherForecast{
+		Current:  *data,
+		Forecast: forecastFromDaily(report.Daily, 1, DefaultLanguage, cityLocation(CityCoordinates{Timezone: "Europe/Zagreb"})),
+	})
+	if got := body.Current.Temperature; got != 59 {
+		t.Errorf("imperial Temperature = %d, want 59", got)
+	}
+	if got := body.Current.WindSpeed; got != 6 {
+		t.Errorf("imperial WindSpeed = %d, want 6", got)
+	}
+	if day := body.Forecast[0]; day.High != 59 || day.Low != 40 {
+		t.Errorf("imperial High/Low = %d/%d, want 59/40", day.High, day.Low)
+	}
+}
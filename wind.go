@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// LocalWind names an Adriatic wind regime; empty when none applies
+type LocalWind string
+
+const (
+	WindBura     LocalWind = "bura"     // cold, gusty north-easterly off the coastal mountains
+	WindJugo     LocalWind = "jugo"     // humid south-easterly bringing rain and high seas
+	WindMaestral LocalWind = "maestral" // summer afternoon sea breeze from the north-west
+)
+
+// Local wind thresholds, km/h
+const (
+	BuraMinGusts    = 40
+	BuraGustFactor  = 1.5 // bura gusts are typically well above the mean speed
+	JugoMinSpeed    = 20
+	MaestralMinWind = 10
+	MaestralMaxWind = 40
+)
+
+// beaufortLimits are the upper wind speeds (km/h) of Beaufort forces 0-11
+var beaufortLimits = [...]float64{1, 6, 12, 20, 29, 39, 50, 62, 75, 89, 103, 118}
+
+// beaufort returns the Beaufort force (0-12) of a wind speed in km/h
+func beaufort(kmh float64) int {
+	for force, limit := range beaufortLimits {
+		if kmh < limit {
+			return force
+		}
+	}
+	return len(beaufortLimits)
+}
+
+// compassPoint returns the 16-point compass index (0 = N, 4 = E, ...) of a direction in degrees
+func compassPoint(degrees int) int {
+	d := math.Mod(float64(degrees), 360)
+	if d < 0 {
+		d += 360
+	}
+	return int(math.Round(d/22.5)) % 16
+}
+
+// inSector reports whether a direction lies between from and to degrees, clockwise
+func inSector(degrees, from, to int) bool {
+	d := (degrees%360 + 360) % 360
+	if from <= to {
+		return d >= from && d <= to
+	}
+	return d >= from || d <= to
+}
+
+// classifyLocalWind names the Adriatic wind regime for a coastal site, given the
+// direction the wind comes from, mean speed and gusts in km/h, and local time
+func classifyLocalWind(coastal bool, direction int, speed, gusts float64, local time.Time) LocalWind {
+	if !coastal {
+		return ""
+	}
+	switch {
+	case inSector(direction, 10, 80) && gusts >= BuraMinGusts && gusts >= speed*BuraGustFactor:
+		return WindBura
+	case inSector(direction, 100, 170) && speed >= JugoMinSpeed:
+		return WindJugo
+	case inSector(direction, 280, 340) && speed >= MaestralMinWind && speed <= MaestralMaxWind &&
+		local.Month() >= time.June && local.Month() <= time.September &&
+		local.Hour() >= 11 && local.Hour() < 19:
+		return WindMaestral
+	}
+	return ""
+}